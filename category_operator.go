@@ -13,6 +13,13 @@ type CategoryOperator interface {
 type CategoryOperatorImpl struct {
 	mu                  sync.RWMutex
 	processByCategories map[string][]string
+	patterns            []categoryPattern
+}
+
+// categoryPattern is a glob or regular expression member of a category
+type categoryPattern struct {
+	pattern  namePattern
+	category string
 }
 
 func newCategoryOperator() CategoryOperator {
@@ -25,23 +32,43 @@ func (c *CategoryOperatorImpl) GetCategoriesOf(process string) []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	categories := c.processByCategories[process]
-	if categories == nil {
+	if categories == nil && len(c.patterns) == 0 {
 		return nil
 	}
 	copied := make([]string, len(categories))
 	copy(copied, categories)
+	for _, p := range c.patterns {
+		if p.pattern.match(process) && !existElementInSlice(copied, p.category) {
+			copied = append(copied, p.category)
+		}
+	}
+	if len(copied) == 0 {
+		return nil
+	}
 	return copied
 }
 
+// SetProcessByCategories replaces the categories. Members can be exact process
+// names or patterns, which are compiled here once; invalid patterns are
+// skipped, as ValidateConfig reports them before the config is applied.
 func (c *CategoryOperatorImpl) SetProcessByCategories(categoriesToProcesses map[string][]string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.processByCategories = make(map[string][]string)
+	c.patterns = nil
 
 	for category, processes := range categoriesToProcesses {
 		for _, proc := range processes {
-			c.processByCategories[proc] = append(c.processByCategories[proc], category)
+			pattern, err := compileNamePattern(proc)
+			if err != nil {
+				continue
+			}
+			if pattern.isLiteral() {
+				c.processByCategories[proc] = append(c.processByCategories[proc], category)
+				continue
+			}
+			c.patterns = append(c.patterns, categoryPattern{pattern: pattern, category: category})
 		}
 	}
 }
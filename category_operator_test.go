@@ -69,3 +69,30 @@ func TestCategoryOperator_ResetOverridesPrevious(t *testing.T) {
 		t.Errorf("after reset, expected [second] for 'y', but got %v", got)
 	}
 }
+
+func TestCategoryOperator_Patterns(t *testing.T) {
+	op := newCategoryOperator()
+	op.SetProcessByCategories(map[string][]string{
+		"browsers": {"chrome*", "firefox"},
+		"games":    {`re:^game-v[0-9.]+$`, "game-v1.0"},
+	})
+
+	tests := []struct {
+		proc string
+		want []string
+	}{
+		{"chrome", []string{"browsers"}},
+		{"chrome_crashpad_handler", []string{"browsers"}},
+		{"firefox", []string{"browsers"}},
+		{"game-v2.3", []string{"games"}},
+		{"game-v1.0", []string{"games"}},
+		{"game-launcher", nil},
+	}
+
+	for _, tt := range tests {
+		got := op.GetCategoriesOf(tt.proc)
+		if !equalUnordered(got, tt.want) {
+			t.Errorf("GetCategoriesOf(%q): expected %v, got %v", tt.proc, tt.want, got)
+		}
+	}
+}
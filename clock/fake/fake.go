@@ -0,0 +1,188 @@
+// Package fake provides a clock.Clock that only moves when told to, so code
+// that waits on timers can be tested without sleeping.
+package fake
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/joaogabriel01/sleego/clock"
+)
+
+// Clock is a clock.Clock whose time is changed with Advance and Set. Timers
+// and tickers fire when the time reaches them; like the real ones, a ticker
+// whose value isn't received drops the ticks that follow.
+type Clock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a pending timer or ticker
+type waiter struct {
+	when   time.Time
+	period time.Duration // zero for timers
+	c      chan time.Time
+}
+
+// NewClock returns a fake clock set to now
+func NewClock(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTimer(d time.Duration) clock.Timer {
+	return &fakeTimer{clock: c, w: c.add(d, 0)}
+}
+
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.add(d, 0).c
+}
+
+func (c *Clock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return &fakeTicker{clock: c, w: c.add(d, d)}
+}
+
+// Advance moves the time forward by d, firing the timers and tickers that
+// are due in the order they are due
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(c.now.Add(d))
+}
+
+// Set moves the time to t, which may be in the past. Nothing fires when the
+// time goes backwards.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(t)
+}
+
+// Waiters returns the number of pending timers and tickers
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until at least n timers and tickers are pending, so a
+// test knows that the code under test is waiting before it advances the time
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *Clock) setLocked(t time.Time) {
+	c.now = t
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool {
+			return c.waiters[i].when.Before(c.waiters[j].when)
+		})
+		if len(c.waiters) == 0 || c.waiters[0].when.After(t) {
+			return
+		}
+		w := c.waiters[0]
+		select {
+		case w.c <- w.when:
+		default:
+		}
+		if w.period == 0 {
+			c.waiters = c.waiters[1:]
+			continue
+		}
+		for !w.when.After(t) {
+			w.when = w.when.Add(w.period)
+		}
+	}
+}
+
+func (c *Clock) add(d, period time.Duration) *waiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &waiter{when: c.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+	// Timers with a non-positive duration fire right away, as real ones do
+	c.setLocked(c.now)
+	return w
+}
+
+// remove reports whether w was still pending
+func (c *Clock) remove(w *waiter) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pending := range c.waiters {
+		if pending == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// reset reschedules w d from now and reports whether it was still pending
+func (c *Clock) reset(w *waiter, d, period time.Duration) bool {
+	active := c.remove(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.when, w.period = c.now.Add(d), period
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+	c.setLocked(c.now)
+	return active
+}
+
+type fakeTimer struct {
+	clock *Clock
+	w     *waiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	return t.clock.remove(t.w)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	return t.clock.reset(t.w, d, 0)
+}
+
+type fakeTicker struct {
+	clock *Clock
+	w     *waiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.remove(t.w)
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.reset(t.w, d, d)
+}
+
+var _ clock.Clock = &Clock{}
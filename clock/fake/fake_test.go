@@ -0,0 +1,132 @@
+package fake
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+
+func received(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestClock_Timer(t *testing.T) {
+	c := NewClock(start)
+	timer := c.NewTimer(time.Minute)
+
+	c.Advance(59 * time.Second)
+	if _, ok := received(timer.C()); ok {
+		t.Fatal("Timer fired before it was due")
+	}
+	c.Advance(time.Second)
+	got, ok := received(timer.C())
+	if !ok {
+		t.Fatal("Expected the timer to fire")
+	}
+	if want := start.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("Timer sent %v, want %v", got, want)
+	}
+	if timer.Stop() {
+		t.Error("Stop() = true for a timer that already fired")
+	}
+	if c.Waiters() != 0 {
+		t.Errorf("Waiters() = %d, want 0", c.Waiters())
+	}
+}
+
+func TestClock_TimerStopAndReset(t *testing.T) {
+	c := NewClock(start)
+	timer := c.NewTimer(time.Minute)
+	if !timer.Stop() {
+		t.Error("Stop() = false for a pending timer")
+	}
+	c.Advance(time.Hour)
+	if _, ok := received(timer.C()); ok {
+		t.Fatal("Stopped timer fired")
+	}
+
+	timer.Reset(time.Second)
+	c.Advance(time.Second)
+	if _, ok := received(timer.C()); !ok {
+		t.Error("Expected the reset timer to fire")
+	}
+}
+
+func TestClock_After(t *testing.T) {
+	c := NewClock(start)
+	if _, ok := received(c.After(0)); !ok {
+		t.Error("After(0) should fire right away")
+	}
+	after := c.After(time.Second)
+	c.Set(start.Add(time.Hour))
+	if _, ok := received(after); !ok {
+		t.Error("Expected After to fire once the time is set past it")
+	}
+}
+
+func TestClock_Ticker(t *testing.T) {
+	c := NewClock(start)
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	c.Advance(time.Second)
+	if got, ok := received(ticker.C()); !ok || !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("Expected a tick at %v, got %v (%v)", start.Add(time.Second), got, ok)
+	}
+
+	// Ticks that aren't received are dropped
+	c.Advance(5 * time.Second)
+	if _, ok := received(ticker.C()); !ok {
+		t.Fatal("Expected a tick")
+	}
+	if _, ok := received(ticker.C()); ok {
+		t.Fatal("Expected missed ticks to be dropped")
+	}
+
+	ticker.Reset(time.Minute)
+	c.Advance(time.Second)
+	if _, ok := received(ticker.C()); ok {
+		t.Fatal("Ticker fired with its old interval after Reset")
+	}
+	c.Advance(time.Minute)
+	if _, ok := received(ticker.C()); !ok {
+		t.Fatal("Expected a tick with the new interval")
+	}
+}
+
+func TestClock_FiresInOrder(t *testing.T) {
+	c := NewClock(start)
+	late := c.After(2 * time.Minute)
+	early := c.After(time.Minute)
+	c.Advance(time.Hour)
+
+	if got, _ := received(early); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("Early timer sent %v", got)
+	}
+	if got, _ := received(late); !got.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("Late timer sent %v", got)
+	}
+}
+
+func TestClock_BlockUntil(t *testing.T) {
+	c := NewClock(start)
+	done := make(chan struct{})
+	go func() {
+		<-c.After(time.Minute)
+		close(done)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the waiting goroutine to be released")
+	}
+}
@@ -1,22 +1,39 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
-	"time"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/joaogabriel01/sleego"
 	"github.com/joaogabriel01/sleego/internal/logger"
 )
 
 func main() {
-	ctx := context.Background()
 	configPath := flag.String("config", "./config.json", "Path to config file")
 	logLevel := flag.String("loglevel", "info", "Log level (debug, info, warn, error)")
+	dryRun := flag.Bool("dry-run", false, "Only log the processes that would be killed and the shutdown, without executing them")
+	stateDir := flag.String("state-dir", defaultStateDir(), "Directory where usage state is persisted across restarts (empty to disable)")
+	controlSocket := flag.String("control-socket", defaultControlSocket(), "Unix socket serving the control API (empty to disable)")
+	controlGroup := flag.String("control-group", "", "Group allowed to use the control socket besides its owner")
+	hashPIN := flag.Bool("hash-pin", false, "Read a PIN from standard input, print its hash for overrides.pin_hash and exit")
 	flag.Parse()
+	if *hashPIN {
+		if err := printPINHash(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	fmt.Println("Log level set to:", *logLevel)
 
 	if *logLevel != "debug" && *logLevel != "info" && *logLevel != "warn" && *logLevel != "error" {
@@ -29,46 +46,168 @@ func main() {
 	}
 
 	loader := &sleego.Loader{}
-	categoryOp := sleego.GetCategoryOperator()
-	config, err := loadConfig(*configPath, loader, categoryOp)
+	config, err := loadConfig(*configPath, loader)
 	if err != nil {
 		loggerInstance.Error(err.Error())
 		os.Exit(1)
 	}
 
-	monitor := &sleego.ProcessorMonitorImpl{}
-	appPolicy := sleego.NewProcessPolicyImpl(monitor, categoryOp, nil, nil)
+	var policyOpts []sleego.ProcessPolicyOption
+	var shutdownOpts []sleego.ShutdownPolicyOption
+	if *dryRun {
+		loggerInstance.Info("Dry run enabled, no process will be killed and the system will not shut down")
+		policyOpts = append(policyOpts, sleego.WithDryRun())
+		shutdownOpts = append(shutdownOpts, sleego.WithShutdownDryRun())
+	}
+
+	// A dry run may preview a config next to the running daemon, so it must
+	// not overwrite the usage and suspended processes the daemon persists
+	if *stateDir != "" && !*dryRun {
+		loggerInstance.Info("Persisting state in: " + *stateDir)
+		policyOpts = append(policyOpts, sleego.WithStateStore(sleego.NewFileStateStore(*stateDir)))
+	}
 
-	loggerInstance.Info("Starting process policy with config: " + *configPath)
-	go appPolicy.Apply(ctx, config.Apps)
+	engine, err := sleego.NewEngine(config,
+		sleego.WithProcessPolicyOptions(policyOpts...),
+		sleego.WithShutdownPolicyOptions(shutdownOpts...),
+	)
+	if err != nil {
+		loggerInstance.Error(err.Error())
+		os.Exit(1)
+	}
 
-	if config.Shutdown != "" {
-		shutdownChannel := make(chan string)
-		shutdownPolicy := sleego.NewShutdownPolicyImpl(shutdownChannel, []int{})
-		shutdownTime, err := time.Parse("15:04", config.Shutdown)
-		if err != nil {
-			loggerInstance.Error("Error parsing shutdown time: " + err.Error())
+	// Listen before starting, so a second instance refuses to run next to the
+	// one already serving the socket
+	var controlListener net.Listener
+	if *controlSocket != "" {
+		if controlListener, err = sleego.ListenControl(*controlSocket, *controlGroup); err != nil {
+			loggerInstance.Error("Error listening for the control API: " + err.Error())
 			os.Exit(1)
 		}
-
-		loggerInstance.Info("Starting shutdown policy with config: " + *configPath)
-		go shutdownPolicy.Apply(ctx, shutdownTime)
+		defer controlListener.Close()
 	}
 
-	select {}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	loggerInstance.Info("Starting policies with config: " + *configPath)
+	if err := engine.Start(ctx); err != nil {
+		loggerInstance.Error(err.Error())
+		os.Exit(1)
+	}
+	go watchReloads(ctx, *configPath, loader, engine, loggerInstance)
+	if controlListener != nil {
+		go serveControl(ctx, controlListener, engine, func() error {
+			return reload(*configPath, loader, engine)
+		}, loggerInstance)
+	}
 
+	if err := engine.Wait(); err != nil {
+		loggerInstance.Error(err.Error())
+		os.Exit(1)
+	}
+	loggerInstance.Info("Stopped")
 }
 
-func loadConfig(path string, loader sleego.ConfigLoader, categoryOp sleego.CategoryOperator) (sleego.FileConfig, error) {
+func loadConfig(path string, loader sleego.ConfigLoader) (sleego.FileConfig, error) {
 	config, err := loader.Load(path)
 	if err != nil {
 		return sleego.FileConfig{}, fmt.Errorf("Error loading config file: %w", err)
 	}
+	return config, nil
+}
 
-	if err := sleego.ValidateConfig(config); err != nil {
-		return sleego.FileConfig{}, fmt.Errorf("Invalid config: %w", err)
+// watchReloads reloads the config on SIGHUP and whenever the file changes
+func watchReloads(ctx context.Context, configPath string, loader sleego.ConfigLoader, engine *sleego.Engine, logger logger.Logger) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	changes, err := sleego.WatchConfig(ctx, configPath)
+	if err != nil {
+		logger.Error("Error watching config file, reload with SIGHUP instead: " + err.Error())
 	}
 
-	categoryOp.SetProcessByCategories(config.Categories)
-	return config, nil
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			logger.Info("Received SIGHUP, reloading config")
+		case _, ok := <-changes:
+			if !ok {
+				// The watch ends with ctx, or early when reading it fails
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Error("Stopped watching config file, reload with SIGHUP instead")
+				changes = nil
+				continue
+			}
+			logger.Info("Config file changed, reloading config")
+		}
+		if err := reload(configPath, loader, engine); err != nil {
+			logger.Error(err.Error() + ", keeping the previous config")
+			continue
+		}
+		logger.Info("Reloaded config: " + configPath)
+	}
+}
+
+// reload loads the config file and swaps it into the engine. When the new
+// config is invalid nothing is changed.
+func reload(configPath string, loader sleego.ConfigLoader, engine *sleego.Engine) error {
+	config, err := loadConfig(configPath, loader)
+	if err != nil {
+		return err
+	}
+	return engine.Reload(config)
+}
+
+// printPINHash hashes the PIN on the first line of in
+func printPINHash(in io.Reader, out io.Writer) error {
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("error reading PIN: %w", err)
+	}
+	hash, err := sleego.HashPIN(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, hash)
+	return err
+}
+
+// serveControl runs the control API until ctx is done. Failing to serve it
+// is logged but doesn't stop the policies.
+func serveControl(ctx context.Context, listener net.Listener, engine *sleego.Engine, reload func() error, logger logger.Logger) {
+	server, err := sleego.NewControlServer(engine, reload)
+	if err != nil {
+		listener.Close()
+		logger.Error(err.Error())
+		return
+	}
+	logger.Info("Serving the control API on: " + listener.Addr().String())
+	if err := server.Serve(ctx, listener); err != nil {
+		logger.Error("Error serving the control API: " + err.Error())
+	}
+}
+
+// defaultControlSocket is /run/sleego.sock when running as root and the
+// user's runtime directory otherwise, if there is one
+func defaultControlSocket() string {
+	if os.Geteuid() == 0 {
+		return "/run/sleego.sock"
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "sleego.sock")
+	}
+	return ""
+}
+
+func defaultStateDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sleego")
 }
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/joaogabriel01/sleego"
@@ -28,29 +31,78 @@ func (r *recordingCategoryOperator) SetProcessByCategories(categories map[string
 	r.categories = categories
 }
 
-func TestLoadConfigSetsCategoriesOnOperator(t *testing.T) {
-	categories := map[string][]string{
-		"games": {"steam.exe"},
+func newTestEngine(t *testing.T, config sleego.FileConfig, categoryOp sleego.CategoryOperator) *sleego.Engine {
+	t.Helper()
+	engine, err := sleego.NewEngine(config, sleego.WithCategoryOperator(categoryOp))
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
 	}
+	return engine
+}
+
+func TestReloadSwapsConfig(t *testing.T) {
+	apps := []sleego.AppConfig{{Name: "games", AllowedFrom: "09:00", AllowedTo: "17:00"}}
+	categories := map[string][]string{"games": {"steam.exe"}}
+	categoryOp := &recordingCategoryOperator{}
+	engine := newTestEngine(t, sleego.FileConfig{}, categoryOp)
+
+	loader := fakeConfigLoader{config: sleego.FileConfig{Apps: apps, Categories: categories}}
+	if err := reload("config.json", loader, engine); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+
+	if got := engine.ProcessPolicy().Apps(); !reflect.DeepEqual(got, apps) {
+		t.Errorf("Apps() = %v, want %v", got, apps)
+	}
+	if !reflect.DeepEqual(categoryOp.categories, categories) {
+		t.Errorf("SetProcessByCategories() categories = %v, want %v", categoryOp.categories, categories)
+	}
+}
+
+func TestReloadKeepsConfigWhenInvalid(t *testing.T) {
+	apps := []sleego.AppConfig{{Name: "games", AllowedFrom: "09:00", AllowedTo: "17:00"}}
+	categoryOp := &recordingCategoryOperator{}
+	engine := newTestEngine(t, sleego.FileConfig{Apps: apps}, categoryOp)
+	engine.ProcessPolicy().SetApps(apps)
+
 	loader := fakeConfigLoader{
 		config: sleego.FileConfig{
-			Apps: []sleego.AppConfig{
-				{Name: "games", AllowedFrom: "09:00", AllowedTo: "17:00"},
-			},
-			Categories: categories,
+			Apps:       []sleego.AppConfig{{Name: "games", AllowedFrom: "25:00", AllowedTo: "17:00"}},
+			Categories: map[string][]string{"games": {"steam.exe"}},
 		},
 	}
-	categoryOp := &recordingCategoryOperator{}
+	if err := reload("config.json", loader, engine); err == nil {
+		t.Fatal("Expected reload() to reject an invalid config")
+	}
+	if got := engine.ProcessPolicy().Apps(); !reflect.DeepEqual(got, apps) {
+		t.Errorf("Apps() = %v, want the previous %v", got, apps)
+	}
+	if categoryOp.categories != nil {
+		t.Errorf("Categories should not be replaced by an invalid config, got %v", categoryOp.categories)
+	}
 
-	config, err := loadConfig("config.json", loader, categoryOp)
-	if err != nil {
-		t.Fatalf("loadConfig() error = %v", err)
+	if err := reload("config.json", fakeConfigLoader{err: errors.New("file not found")}, engine); err == nil {
+		t.Fatal("Expected reload() to fail when the config cannot be loaded")
 	}
+	if got := engine.ProcessPolicy().Apps(); !reflect.DeepEqual(got, apps) {
+		t.Errorf("Apps() = %v, want the previous %v", got, apps)
+	}
+}
 
-	if !reflect.DeepEqual(config.Categories, categories) {
-		t.Fatalf("loadConfig() categories = %v, want %v", config.Categories, categories)
+func TestPrintPINHash(t *testing.T) {
+	var out bytes.Buffer
+	if err := printPINHash(strings.NewReader("1234\n"), &out); err != nil {
+		t.Fatalf("printPINHash() error = %v", err)
 	}
-	if !reflect.DeepEqual(categoryOp.categories, categories) {
-		t.Fatalf("SetProcessByCategories() categories = %v, want %v", categoryOp.categories, categories)
+	hash := strings.TrimSpace(out.String())
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$") {
+		t.Errorf("Unexpected hash %q", hash)
+	}
+	if err := sleego.ValidateConfig(sleego.FileConfig{Overrides: sleego.OverrideConfig{PinHash: hash}}); err != nil {
+		t.Errorf("Expected the printed hash to be accepted in the config, got %v", err)
+	}
+
+	if err := printPINHash(strings.NewReader("\n"), &out); err == nil {
+		t.Error("Expected an empty PIN to be rejected")
 	}
 }
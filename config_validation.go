@@ -2,6 +2,8 @@ package sleego
 
 import (
 	"fmt"
+	"os"
+	"runtime"
 	"strings"
 	"time"
 )
@@ -16,18 +18,258 @@ func ValidateConfig(cfg FileConfig) error {
 		}
 	}
 
+	if err := validateConfigPowerAction(runtime.GOOS, cfg.PowerAction); err != nil {
+		return err
+	}
+
+	if cfg.QuotaReset != "" {
+		if err := validateConfigTime("quota_reset", cfg.QuotaReset); err != nil {
+			return err
+		}
+	}
+
+	if cfg.PollInterval != "" {
+		interval, err := time.ParseDuration(cfg.PollInterval)
+		if err != nil {
+			return fmt.Errorf("poll_interval must be a duration such as 5s or 1m: %w", err)
+		}
+		if interval < minPollInterval || interval > maxPollInterval {
+			return fmt.Errorf("poll_interval must be between %v and %v", minPollInterval, maxPollInterval)
+		}
+	}
+
+	for i, minutes := range cfg.ShutdownWarnings {
+		if minutes <= 0 || minutes > 24*60 {
+			return fmt.Errorf("shutdown_warnings[%d] must be between 1 and %d minutes", i, 24*60)
+		}
+	}
+
+	for i, minutes := range cfg.KillWarnings {
+		if minutes <= 0 || minutes > 24*60 {
+			return fmt.Errorf("kill_warnings[%d] must be between 1 and %d minutes", i, 24*60)
+		}
+	}
+
+	if _, err := NewNotifiers(cfg.Notifiers); err != nil {
+		return err
+	}
+
+	if cfg.Overrides.PinHash != "" {
+		if _, err := parsePINHash(cfg.Overrides.PinHash); err != nil {
+			return fmt.Errorf("overrides.pin_hash: %w", err)
+		}
+	}
+	if cfg.Overrides.MaxPerDay < 0 {
+		return fmt.Errorf("overrides.max_per_day must not be negative")
+	}
+
+	if err := validateConfigSnooze(cfg); err != nil {
+		return err
+	}
+	if err := validateConfigCurfew(cfg); err != nil {
+		return err
+	}
+
 	for i, app := range cfg.Apps {
 		if strings.TrimSpace(app.Name) == "" {
 			return fmt.Errorf("apps[%d].name is required", i)
 		}
-		if err := validateConfigTime(fmt.Sprintf("apps[%d].allowed_from", i), app.AllowedFrom); err != nil {
+		if _, err := compileNamePattern(app.Name); err != nil {
+			return fmt.Errorf("apps[%d].name: %w", i, err)
+		}
+		if err := validateConfigMatch(fmt.Sprintf("apps[%d].match", i), app.Match); err != nil {
+			return err
+		}
+		if err := validateConfigNotProtected(fmt.Sprintf("apps[%d]", i), app, cfg); err != nil {
+			return err
+		}
+		if err := validateConfigAppWindows(fmt.Sprintf("apps[%d]", i), app); err != nil {
+			return err
+		}
+		if err := validateConfigQuota(fmt.Sprintf("apps[%d].daily_quota", i), app.DailyQuota); err != nil {
+			return err
+		}
+		if err := validateConfigGracePeriod(fmt.Sprintf("apps[%d].grace_period", i), app.GracePeriod); err != nil {
+			return err
+		}
+		switch app.Action {
+		case "", ActionKill:
+		case ActionSuspend:
+			if app.GracePeriod != "" {
+				return fmt.Errorf("apps[%d].grace_period only applies to the %q action", i, ActionKill)
+			}
+		default:
+			return fmt.Errorf("apps[%d].action must be %q or %q", i, ActionKill, ActionSuspend)
+		}
+	}
+
+	for category, members := range cfg.Categories {
+		for i, member := range members {
+			if _, err := compileNamePattern(member); err != nil {
+				return fmt.Errorf("categories[%q][%d]: %w", category, i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateConfigNotProtected rejects rules whose name, or the members of the
+// category they refer to, would match a protected process name. Rules with a
+// match on exe, cmdline or user can't be checked by name alone; protected
+// processes are still skipped when the policy runs.
+func validateConfigNotProtected(field string, app AppConfig, cfg FileConfig) error {
+	if app.Match != nil {
+		return nil
+	}
+	patterns := []string{app.Name}
+	patterns = append(patterns, cfg.Categories[app.Name]...)
+	for _, raw := range patterns {
+		pattern, err := compileNamePattern(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		for _, name := range protectedNames(cfg.Protected) {
+			if pattern.match(name) {
+				return fmt.Errorf("%s: %q would match the protected process %q", field, raw, name)
+			}
+		}
+	}
+	return nil
+}
+
+func validateConfigMatch(field string, m *ProcessMatch) error {
+	if m == nil {
+		return nil
+	}
+	if m.Exe == "" && m.Cmdline == "" && m.User == "" {
+		return fmt.Errorf("%s needs at least one of exe, cmdline or user", field)
+	}
+	fields := []struct{ name, raw string }{{"exe", m.Exe}, {"cmdline", m.Cmdline}, {"user", m.User}}
+	for _, f := range fields {
+		if f.raw == "" {
+			continue
+		}
+		if _, err := compileNamePattern(f.raw); err != nil {
+			return fmt.Errorf("%s.%s: %w", field, f.name, err)
+		}
+	}
+	// Rules cover the processes of every user, so ~ has no single home to expand to
+	if strings.HasPrefix(m.Exe, "~") {
+		return fmt.Errorf("%s.exe: %q must be an absolute path, ~ is not expanded", field, m.Exe)
+	}
+	return nil
+}
+
+func validateConfigAppWindows(field string, app AppConfig) error {
+	// The top-level window is optional once windows, a schedule or a quota are given
+	if (len(app.Windows) == 0 && len(app.Schedule) == 0 && app.DailyQuota == "") || app.AllowedFrom != "" || app.AllowedTo != "" {
+		if err := validateConfigWindow(field, TimeWindow{AllowedFrom: app.AllowedFrom, AllowedTo: app.AllowedTo}); err != nil {
 			return err
 		}
-		if err := validateConfigTime(fmt.Sprintf("apps[%d].allowed_to", i), app.AllowedTo); err != nil {
+	}
+	for i, window := range app.Windows {
+		if err := validateConfigWindow(fmt.Sprintf("%s.windows[%d]", field, i), window); err != nil {
 			return err
 		}
 	}
+	if err := validateConfigSchedule(field+".schedule", app.Schedule); err != nil {
+		return err
+	}
+	return validateConfigOverlaps(field, app)
+}
+
+func validateConfigWindow(field string, window TimeWindow) error {
+	if err := validateConfigTime(field+".allowed_from", window.AllowedFrom); err != nil {
+		return err
+	}
+	return validateConfigTime(field+".allowed_to", window.AllowedTo)
+}
+
+func validateConfigSchedule(field string, schedule map[string]TimeWindows) error {
+	seen := make(map[time.Weekday]string)
+	for key, windows := range schedule {
+		days, err := parseWeekdays(key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		for day := range days {
+			if other, ok := seen[day]; ok {
+				return fmt.Errorf("%s: %s is listed in both %q and %q", field, day, other, key)
+			}
+			seen[day] = key
+		}
+		for i, window := range windows {
+			if err := validateConfigWindow(fmt.Sprintf("%s[%q][%d]", field, key, i), window); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateConfigOverlaps rejects windows that overlap on any weekday, including
+// windows that run past midnight into the windows of the following day.
+func validateConfigOverlaps(field string, app AppConfig) error {
+	today := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	tomorrow := today.AddDate(0, 0, 1)
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		current := app.windowsOn(day)
+		next := app.windowsOn((day + 1) % 7)
+		for i, a := range current {
+			aStart, aEnd, err := windowBounds(a, today)
+			if err != nil {
+				return fmt.Errorf("%s: %w", field, err)
+			}
+			for _, b := range current[i+1:] {
+				bStart, bEnd, err := windowBounds(b, today)
+				if err != nil {
+					return fmt.Errorf("%s: %w", field, err)
+				}
+				if aStart.Before(bEnd) && bStart.Before(aEnd) {
+					return fmt.Errorf("%s: windows %s-%s and %s-%s overlap on %s", field, a.AllowedFrom, a.AllowedTo, b.AllowedFrom, b.AllowedTo, day)
+				}
+			}
+			for _, b := range next {
+				bStart, bEnd, err := windowBounds(b, tomorrow)
+				if err != nil {
+					return fmt.Errorf("%s: %w", field, err)
+				}
+				if aStart.Before(bEnd) && bStart.Before(aEnd) {
+					return fmt.Errorf("%s: window %s-%s on %s overlaps %s-%s on %s", field, a.AllowedFrom, a.AllowedTo, day, b.AllowedFrom, b.AllowedTo, (day+1)%7)
+				}
+			}
+		}
+	}
+	return nil
+}
 
+func validateConfigQuota(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	quota, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s must be a duration such as 2h or 90m: %w", field, err)
+	}
+	if quota <= 0 || quota > 24*time.Hour {
+		return fmt.Errorf("%s must be between 0 and 24h", field)
+	}
+	return nil
+}
+
+func validateConfigGracePeriod(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	grace, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s must be a duration such as 10s or 1m: %w", field, err)
+	}
+	if grace < 0 || grace > maxGracePeriod {
+		return fmt.Errorf("%s must be between 0 and %v", field, maxGracePeriod)
+	}
 	return nil
 }
 
@@ -50,3 +292,79 @@ func validateConfigTime(field, value string) error {
 func isDigit(b byte) bool {
 	return b >= '0' && b <= '9'
 }
+
+// validateConfigPowerAction checks that the power action is known and, unless
+// it is the default, supported on goos
+func validateConfigPowerAction(goos, action string) error {
+	if _, err := lookupPowerAction(action); err != nil {
+		return fmt.Errorf("power_action: %w", err)
+	}
+	if action == "" {
+		return nil
+	}
+	if _, err := powerCommand(goos, action, os.Getenv); err != nil {
+		return fmt.Errorf("power_action: %w", err)
+	}
+	return nil
+}
+
+// validateConfigSnooze checks that snoozes are positive and that the latest
+// time follows the shutdown by no more than maxOverride
+func validateConfigSnooze(cfg FileConfig) error {
+	snooze := cfg.Snooze
+	if snooze.Count < 0 || snooze.Minutes < 0 {
+		return fmt.Errorf("snooze.count and snooze.minutes must not be negative")
+	}
+	if (snooze.Count == 0) != (snooze.Minutes == 0) {
+		return fmt.Errorf("snooze.count and snooze.minutes must be set together")
+	}
+	if snooze.Minutes > int(maxOverride/time.Minute) {
+		return fmt.Errorf("snooze.minutes must be at most %d", int(maxOverride/time.Minute))
+	}
+	if snooze.Latest == "" {
+		return nil
+	}
+	if err := validateConfigTime("snooze.latest", snooze.Latest); err != nil {
+		return err
+	}
+	if cfg.Shutdown == "" {
+		return fmt.Errorf("snooze.latest requires a shutdown time")
+	}
+	shutdown, _ := time.Parse(configTimeLayout, cfg.Shutdown)
+	latest, _ := time.Parse(configTimeLayout, snooze.Latest)
+	if gap := nextShutdownTime(shutdown, latest).Sub(shutdown); gap == 0 || gap > maxOverride {
+		return fmt.Errorf("snooze.latest must be after the shutdown and at most %v later", maxOverride)
+	}
+	return nil
+}
+
+// validateConfigCurfew checks the curfew times and grace period. The curfew is
+// enforced by the shutdown policy, so it requires a shutdown time.
+func validateConfigCurfew(cfg FileConfig) error {
+	curfew := cfg.Curfew
+	if curfew == (CurfewConfig{}) {
+		return nil
+	}
+	if err := validateConfigTime("curfew.from", curfew.From); err != nil {
+		return err
+	}
+	if err := validateConfigTime("curfew.to", curfew.To); err != nil {
+		return err
+	}
+	if curfew.From == curfew.To {
+		return fmt.Errorf("curfew.from and curfew.to must differ")
+	}
+	if curfew.Grace != "" {
+		grace, err := time.ParseDuration(curfew.Grace)
+		if err != nil {
+			return fmt.Errorf("curfew.grace must be a duration such as 5m: %w", err)
+		}
+		if grace < minCurfewGrace || grace > maxCurfewGrace {
+			return fmt.Errorf("curfew.grace must be between %v and %v", minCurfewGrace, maxCurfewGrace)
+		}
+	}
+	if cfg.Shutdown == "" {
+		return fmt.Errorf("curfew requires a shutdown time")
+	}
+	return nil
+}
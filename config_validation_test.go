@@ -87,6 +87,555 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "weekday schedule without fallback window",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", Schedule: map[string]TimeWindows{
+						"mon-fri": {{AllowedFrom: "18:00", AllowedTo: "20:00"}},
+						"sat,sun": {{AllowedFrom: "09:00", AllowedTo: "22:00"}},
+					}},
+				},
+			},
+		},
+		{
+			name: "weekday schedule with fallback window",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", AllowedFrom: "18:00", AllowedTo: "20:00", Schedule: map[string]TimeWindows{
+						"sat,sun": {{AllowedFrom: "09:00", AllowedTo: "22:00"}},
+					}},
+				},
+			},
+		},
+		{
+			name: "weekday schedule with incomplete fallback window",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", AllowedFrom: "18:00", Schedule: map[string]TimeWindows{
+						"sat,sun": {{AllowedFrom: "09:00", AllowedTo: "22:00"}},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown weekday in schedule",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", Schedule: map[string]TimeWindows{
+						"mon-fry": {{AllowedFrom: "18:00", AllowedTo: "20:00"}},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "weekday listed twice in schedule",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", Schedule: map[string]TimeWindows{
+						"mon-fri": {{AllowedFrom: "18:00", AllowedTo: "20:00"}},
+						"fri-sun": {{AllowedFrom: "09:00", AllowedTo: "22:00"}},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid time in schedule",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", Schedule: map[string]TimeWindows{
+						"mon-fri": {{AllowedFrom: "18:00", AllowedTo: "8pm"}},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "multiple disjoint windows",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", AllowedFrom: "07:00", AllowedTo: "08:00", Windows: []TimeWindow{
+						{AllowedFrom: "18:00", AllowedTo: "21:00"},
+					}},
+				},
+			},
+		},
+		{
+			name: "adjacent windows",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", Windows: []TimeWindow{
+						{AllowedFrom: "07:00", AllowedTo: "08:00"},
+						{AllowedFrom: "08:00", AllowedTo: "09:00"},
+					}},
+				},
+			},
+		},
+		{
+			name: "overlapping windows",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", AllowedFrom: "07:00", AllowedTo: "09:00", Windows: []TimeWindow{
+						{AllowedFrom: "08:00", AllowedTo: "10:00"},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "overnight window overlapping next morning",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", Windows: []TimeWindow{
+						{AllowedFrom: "22:00", AllowedTo: "02:00"},
+						{AllowedFrom: "01:00", AllowedTo: "03:00"},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "overnight schedule window overlapping next weekday",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", Schedule: map[string]TimeWindows{
+						"fri": {{AllowedFrom: "22:00", AllowedTo: "02:00"}},
+						"sat": {{AllowedFrom: "01:00", AllowedTo: "03:00"}},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid time in windows",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", Windows: []TimeWindow{
+						{AllowedFrom: "18:00", AllowedTo: ""},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "quota without window",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", DailyQuota: "2h"},
+				},
+				QuotaReset: "04:00",
+			},
+		},
+		{
+			name: "invalid quota",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", DailyQuota: "two hours"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative quota",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", DailyQuota: "-1h"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid quota reset",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", DailyQuota: "2h"},
+				},
+				QuotaReset: "4am",
+			},
+			wantErr: true,
+		},
+		{
+			name: "grace period",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "editor", AllowedFrom: "09:00", AllowedTo: "18:00", GracePeriod: "30s"},
+				},
+			},
+		},
+		{
+			name: "invalid grace period",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "editor", AllowedFrom: "09:00", AllowedTo: "18:00", GracePeriod: "soon"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "grace period too long",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "editor", AllowedFrom: "09:00", AllowedTo: "18:00", GracePeriod: "1h"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "suspend action",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "game", AllowedFrom: "09:00", AllowedTo: "18:00", Action: "suspend"},
+				},
+			},
+		},
+		{
+			name: "unknown action",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "game", AllowedFrom: "09:00", AllowedTo: "18:00", Action: "pause"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "grace period with suspend action",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "game", AllowedFrom: "09:00", AllowedTo: "18:00", Action: "suspend", GracePeriod: "30s"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "notifiers",
+			cfg: FileConfig{
+				Apps:      []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Notifiers: []NotifierConfig{{Type: "desktop"}, {Type: "file", Path: "/var/log/sleego.jsonl"}},
+			},
+		},
+		{
+			name: "unknown notifier",
+			cfg: FileConfig{
+				Apps:      []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Notifiers: []NotifierConfig{{Type: "pager"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "webhook notifier",
+			cfg: FileConfig{
+				Apps:      []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Notifiers: []NotifierConfig{{Type: "webhook", URL: "https://example.com/hook", Retries: 3, Timeout: "5s"}},
+			},
+		},
+		{
+			name: "webhook notifier without url",
+			cfg: FileConfig{
+				Apps:      []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Notifiers: []NotifierConfig{{Type: "webhook"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "overrides",
+			cfg: FileConfig{
+				Apps:      []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Overrides: OverrideConfig{PinHash: "pbkdf2-sha256$1000$c2FsdHNhbHRzYWx0$a2V5a2V5a2V5a2V5", MaxPerDay: 2},
+			},
+		},
+		{
+			name: "plain PIN instead of its hash",
+			cfg: FileConfig{
+				Apps:      []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Overrides: OverrideConfig{PinHash: "1234"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative overrides per day",
+			cfg: FileConfig{
+				Apps:      []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Overrides: OverrideConfig{MaxPerDay: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "snooze",
+			cfg: FileConfig{
+				Apps:     []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Shutdown: "23:30",
+				Snooze:   SnoozeConfig{Count: 3, Minutes: 10, Latest: "00:15"},
+			},
+		},
+		{
+			name: "snooze count without minutes",
+			cfg: FileConfig{
+				Apps:   []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Snooze: SnoozeConfig{Count: 3},
+			},
+			wantErr: true,
+		},
+		{
+			name: "snooze latest without shutdown",
+			cfg: FileConfig{
+				Apps:   []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Snooze: SnoozeConfig{Count: 3, Minutes: 10, Latest: "00:15"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "snooze latest equal to shutdown",
+			cfg: FileConfig{
+				Apps:     []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Shutdown: "23:30",
+				Snooze:   SnoozeConfig{Count: 3, Minutes: 10, Latest: "23:30"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "snooze latest too long after shutdown",
+			cfg: FileConfig{
+				Apps:     []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Shutdown: "23:30",
+				Snooze:   SnoozeConfig{Count: 3, Minutes: 10, Latest: "12:00"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "power action",
+			cfg: FileConfig{
+				Apps:        []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Shutdown:    "23:59",
+				PowerAction: "poweroff",
+			},
+		},
+		{
+			name: "unknown power action",
+			cfg: FileConfig{
+				Apps:        []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Shutdown:    "23:59",
+				PowerAction: "sleep",
+			},
+			wantErr: true,
+		},
+		{
+			name: "curfew",
+			cfg: FileConfig{
+				Apps:     []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Shutdown: "23:59",
+				Curfew:   CurfewConfig{From: "23:59", To: "06:00", Grace: "10m"},
+			},
+		},
+		{
+			name: "curfew without shutdown",
+			cfg: FileConfig{
+				Apps:   []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Curfew: CurfewConfig{From: "23:59", To: "06:00"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "curfew without end",
+			cfg: FileConfig{
+				Apps:     []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Shutdown: "23:59",
+				Curfew:   CurfewConfig{From: "23:59"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty curfew",
+			cfg: FileConfig{
+				Apps:     []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Shutdown: "23:59",
+				Curfew:   CurfewConfig{From: "06:00", To: "06:00"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "curfew grace too short",
+			cfg: FileConfig{
+				Apps:     []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				Shutdown: "23:59",
+				Curfew:   CurfewConfig{From: "23:59", To: "06:00", Grace: "10s"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "poll interval",
+			cfg: FileConfig{
+				Apps:         []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				PollInterval: "2s",
+			},
+		},
+		{
+			name: "invalid poll interval",
+			cfg: FileConfig{
+				Apps:         []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				PollInterval: "often",
+			},
+			wantErr: true,
+		},
+		{
+			name: "poll interval too short",
+			cfg: FileConfig{
+				Apps:         []AppConfig{{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"}},
+				PollInterval: "100ms",
+			},
+			wantErr: true,
+		},
+		{
+			name: "shutdown warnings",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+				ShutdownWarnings: []int{10, 2},
+			},
+		},
+		{
+			name: "non-positive shutdown warning",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+				ShutdownWarnings: []int{10, -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "kill warnings",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+				KillWarnings: []int{10, 2},
+			},
+		},
+		{
+			name: "non-positive kill warning",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "code", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+				KillWarnings: []int{10, 0},
+			},
+			wantErr: true,
+		},
+		{
+			name: "glob and regex names",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "chrome*", AllowedFrom: "09:00", AllowedTo: "18:00"},
+					{Name: "re:^game-v[0-9.]+$", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+				Categories: map[string][]string{"games": {"steam*", "re:^game"}},
+			},
+		},
+		{
+			name: "invalid glob name",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "chrome[", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid regex in category",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+				Categories: map[string][]string{"games": {"re:game("}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "match on cmdline and exe",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "java", AllowedFrom: "09:00", AllowedTo: "18:00", Match: &ProcessMatch{Cmdline: "*minecraft*", User: "kid"}},
+					{Name: "*", AllowedFrom: "09:00", AllowedTo: "18:00", Match: &ProcessMatch{Exe: "/home/kid/Games/*"}},
+				},
+			},
+		},
+		{
+			name: "empty match",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "java", AllowedFrom: "09:00", AllowedTo: "18:00", Match: &ProcessMatch{}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "exe in the home directory",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "*", AllowedFrom: "09:00", AllowedTo: "18:00", Match: &ProcessMatch{Exe: "~/Games/*"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid match pattern",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "java", AllowedFrom: "09:00", AllowedTo: "18:00", Match: &ProcessMatch{Cmdline: "re:(minecraft"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "rule matching a built-in protected name",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "sshd", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pattern matching a built-in protected name",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "re:X.*", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "category member matching a protected name",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "games", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+				Categories: map[string][]string{"games": {"steam", "explorer.exe"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "rule matching a configured protected name",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "backup*", AllowedFrom: "09:00", AllowedTo: "18:00"},
+				},
+				Protected: ProtectedConfig{Names: []string{"backup-agent"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "broad name narrowed by match",
+			cfg: FileConfig{
+				Apps: []AppConfig{
+					{Name: "*", AllowedFrom: "09:00", AllowedTo: "18:00", Match: &ProcessMatch{Exe: "/home/kid/Games/*"}},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -98,3 +647,27 @@ func TestValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateConfigPowerAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		goos    string
+		action  string
+		wantErr bool
+	}{
+		{name: "default", goos: "windows"},
+		{name: "poweroff", goos: "darwin", action: PowerOff},
+		{name: "suspend on linux", goos: "linux", action: PowerSuspend},
+		{name: "suspend on windows", goos: "windows", action: PowerSuspend, wantErr: true},
+		{name: "lock on darwin", goos: "darwin", action: PowerLock, wantErr: true},
+		{name: "unknown action", goos: "linux", action: "sleep", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfigPowerAction(tt.goos, tt.action)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
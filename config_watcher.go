@@ -0,0 +1,58 @@
+package sleego
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Time to wait for a burst of file events to settle before notifying. Editors
+// often truncate, write and rename in quick succession when saving.
+const configWatchDebounce = 250 * time.Millisecond
+
+// WatchConfig notifies on the returned channel whenever the file at path is
+// written, replaced or created. Notifications are coalesced, so a receiver that
+// is busy reloading gets a single pending notification. The channel is closed
+// when ctx is done.
+func WatchConfig(ctx context.Context, path string) (<-chan struct{}, error) {
+	return watchConfig(ctx, path)
+}
+
+// debouncer turns bursts of triggers into a single notification on out
+type debouncer struct {
+	mu     sync.Mutex
+	closed bool
+	out    chan struct{}
+	timer  *time.Timer
+}
+
+func newDebouncer() *debouncer {
+	d := &debouncer{out: make(chan struct{}, 1)}
+	d.timer = time.AfterFunc(time.Hour, d.fire)
+	d.timer.Stop()
+	return d
+}
+
+func (d *debouncer) trigger() {
+	d.timer.Reset(configWatchDebounce)
+}
+
+func (d *debouncer) fire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	select {
+	case d.out <- struct{}{}:
+	default:
+	}
+}
+
+func (d *debouncer) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	d.timer.Stop()
+	close(d.out)
+}
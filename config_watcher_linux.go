@@ -0,0 +1,71 @@
+//go:build linux
+
+package sleego
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchConfig uses inotify on the directory of the file, because editors and
+// tools commonly replace the file through a rename instead of writing to it.
+func watchConfig(ctx context.Context, path string) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	dir, name := filepath.Split(filepath.Clean(path))
+	if dir == "" {
+		dir = "."
+	}
+	_, err = unix.InotifyAddWatch(fd, dir, unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO|unix.IN_CREATE)
+	if err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	// A non-blocking descriptor is handled by the runtime poller, so Close
+	// unblocks the pending Read when the context is done
+	file := os.NewFile(uintptr(fd), "inotify")
+	d := newDebouncer()
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+	go func() {
+		defer d.close()
+		buf := make([]byte, 4096)
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				return
+			}
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameStart := offset + unix.SizeofInotifyEvent
+				nameEnd := nameStart + int(event.Len)
+				if nameEnd > n {
+					break
+				}
+				if eventName := trimNulls(buf[nameStart:nameEnd]); eventName == name {
+					d.trigger()
+				}
+				offset = nameEnd
+			}
+		}
+	}()
+	return d.out, nil
+}
+
+func trimNulls(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
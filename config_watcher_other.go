@@ -0,0 +1,43 @@
+//go:build !linux
+
+package sleego
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// How often the file is checked on platforms without inotify
+const configPollInterval = 2 * time.Second
+
+// watchConfig polls the modification time and size of the file
+func watchConfig(ctx context.Context, path string) (<-chan struct{}, error) {
+	last, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDebouncer()
+	go func() {
+		defer d.close()
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil {
+					continue
+				}
+				if !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+					last = info
+					d.trigger()
+				}
+			}
+		}
+	}()
+	return d.out, nil
+}
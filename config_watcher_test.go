@@ -0,0 +1,76 @@
+package sleego
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitForChange(t *testing.T, changes <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a change notification, but none arrived")
+	}
+}
+
+func TestWatchConfig_NotifiesOnWriteAndReplace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := WatchConfig(ctx, path)
+	if err != nil {
+		t.Fatalf("WatchConfig() error = %v", err)
+	}
+
+	// Give platforms that poll a different modification time to notice
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(path, []byte(`{"apps": []}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	waitForChange(t, changes)
+
+	// Editors commonly save by renaming a temporary file over the original
+	tmp := filepath.Join(dir, "config.json.tmp")
+	if err := os.WriteFile(tmp, []byte(`{"apps": [], "shutdown": ""}`), 0644); err != nil {
+		t.Fatalf("Failed to write temp config: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Failed to replace config: %v", err)
+	}
+	waitForChange(t, changes)
+}
+
+func TestWatchConfig_ClosesWhenContextDone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := WatchConfig(ctx, path)
+	if err != nil {
+		t.Fatalf("WatchConfig() error = %v", err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-changes:
+		if ok {
+			// A pending notification may still be delivered before the close
+			if _, ok := <-changes; ok {
+				t.Error("Expected the channel to be closed")
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the channel to be closed after cancellation")
+	}
+}
@@ -0,0 +1,284 @@
+package sleego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/joaogabriel01/sleego/internal/logger"
+)
+
+// Longest a request to the control API may take to be read, and the time
+// running requests get to finish when the server stops
+const controlTimeout = 5 * time.Second
+
+// ControlServer exposes a running Engine as an HTTP/JSON API, meant to be
+// served on a Unix socket by ListenControl. There is no authentication of its
+// own; whoever may open the socket may use every endpoint.
+type ControlServer struct {
+	engine *Engine
+	reload func() error
+	logger logger.Logger
+	mux    *http.ServeMux
+}
+
+// NewControlServer creates the API for engine. The reload endpoint calls
+// reload, usually to read the config file again; it is disabled when nil.
+func NewControlServer(engine *Engine, reload func() error) (*ControlServer, error) {
+	logger, err := logger.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logger: %w", err)
+	}
+	s := &ControlServer{engine: engine, reload: reload, logger: logger, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /v1/config", s.getConfig)
+	s.mux.HandleFunc("GET /v1/processes", s.getProcesses)
+	s.mux.HandleFunc("GET /v1/shutdown", s.getShutdown)
+	s.mux.HandleFunc("POST /v1/shutdown/postpone", s.postPostpone)
+	s.mux.HandleFunc("POST /v1/shutdown/snooze", s.postSnooze)
+	s.mux.HandleFunc("GET /v1/events", s.getEvents)
+	s.mux.HandleFunc("POST /v1/reload", s.postReload)
+	s.mux.HandleFunc("GET /v1/overrides", s.getOverrides)
+	s.mux.HandleFunc("POST /v1/overrides", s.postOverride)
+	s.mux.HandleFunc("DELETE /v1/overrides/{app}", s.deleteOverride)
+	return s, nil
+}
+
+// ServeHTTP handles a request to the API
+func (s *ControlServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve answers requests on listener until ctx is done
+func (s *ControlServer) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{Handler: s, ReadHeaderTimeout: controlTimeout}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), controlTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ListenControl listens on a Unix socket at path that only its owner and,
+// when group is set, the members of that group may open. A socket left
+// behind by a previous run is replaced, but one another instance still
+// listens on is not. The socket file is removed when the listener is closed.
+func ListenControl(path string, group string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, controlTimeout); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another instance is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error removing stale socket: %w", err)
+		}
+	}
+
+	listener, err := listenPrivate(path)
+	if err != nil {
+		return nil, err
+	}
+	mode := os.FileMode(0600)
+	if group != "" {
+		mode = 0660
+		if err := chownGroup(path, group); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("error restricting socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// chownGroup hands the file over to the named group
+func chownGroup(path string, group string) error {
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return fmt.Errorf("unsupported gid %q of group %s", g.Gid, group)
+	}
+	if err := os.Chown(path, -1, gid); err != nil {
+		return fmt.Errorf("error changing socket group: %w", err)
+	}
+	return nil
+}
+
+// shutdownStatus is the response of GET /v1/shutdown and of the requests moving the shutdown
+type shutdownStatus struct {
+	Scheduled   time.Time `json:"scheduled,omitzero"` // Scheduled is unset when no shutdown is configured
+	SnoozesLeft int       `json:"snoozes_left"`
+}
+
+// overrideRequest is the body of POST /v1/overrides and POST /v1/shutdown/postpone
+type overrideRequest struct {
+	App     string `json:"app,omitempty"`
+	Minutes int    `json:"minutes"`
+	PIN     string `json:"pin,omitempty"`
+}
+
+func (s *ControlServer) getConfig(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, http.StatusOK, redactConfig(s.engine.Config()))
+}
+
+// redactConfig hides the secrets a configuration may hold, such as the
+// tokens in webhook headers
+func redactConfig(config FileConfig) FileConfig {
+	if config.Overrides.PinHash != "" {
+		config.Overrides.PinHash = redacted
+	}
+	notifiers := make([]NotifierConfig, len(config.Notifiers))
+	for i, notifier := range config.Notifiers {
+		if len(notifier.Headers) != 0 {
+			headers := make(map[string]string, len(notifier.Headers))
+			for name := range notifier.Headers {
+				headers[name] = redacted
+			}
+			notifier.Headers = headers
+		}
+		notifiers[i] = notifier
+	}
+	config.Notifiers = notifiers
+	return config
+}
+
+// Replaces secret values returned by the API
+const redacted = "<redacted>"
+
+func (s *ControlServer) getProcesses(w http.ResponseWriter, _ *http.Request) {
+	matches, err := s.engine.ProcessPolicy().Matches()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, matches)
+}
+
+func (s *ControlServer) getShutdown(w http.ResponseWriter, _ *http.Request) {
+	scheduled, _ := s.engine.NextShutdown()
+	s.writeJSON(w, http.StatusOK, shutdownStatus{Scheduled: scheduled, SnoozesLeft: s.engine.SnoozesLeft()})
+}
+
+func (s *ControlServer) getEvents(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", value))
+			return
+		}
+	}
+	s.writeJSON(w, http.StatusOK, s.engine.RecentEvents(limit))
+}
+
+func (s *ControlServer) postReload(w http.ResponseWriter, _ *http.Request) {
+	if s.reload == nil {
+		s.writeError(w, http.StatusNotImplemented, errors.New("reloading is not available"))
+		return
+	}
+	if err := s.reload(); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.logger.Info("Reloaded config through the control API")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *ControlServer) getOverrides(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, http.StatusOK, s.engine.ProcessPolicy().Overrides())
+}
+
+func (s *ControlServer) postOverride(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.readOverrideRequest(w, r)
+	if !ok {
+		return
+	}
+	override, err := s.engine.Grant(req.App, time.Duration(req.Minutes)*time.Minute, req.PIN)
+	if err != nil {
+		s.writeError(w, overrideErrorStatus(err), err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, override)
+}
+
+func (s *ControlServer) postPostpone(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.readOverrideRequest(w, r)
+	if !ok {
+		return
+	}
+	scheduled, err := s.engine.PostponeShutdown(time.Duration(req.Minutes)*time.Minute, req.PIN)
+	if err != nil {
+		s.writeError(w, overrideErrorStatus(err), err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, shutdownStatus{Scheduled: scheduled, SnoozesLeft: s.engine.SnoozesLeft()})
+}
+
+func (s *ControlServer) postSnooze(w http.ResponseWriter, _ *http.Request) {
+	scheduled, err := s.engine.Snooze()
+	if err != nil {
+		s.writeError(w, overrideErrorStatus(err), err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, shutdownStatus{Scheduled: scheduled, SnoozesLeft: s.engine.SnoozesLeft()})
+}
+
+func (s *ControlServer) readOverrideRequest(w http.ResponseWriter, r *http.Request) (overrideRequest, bool) {
+	var req overrideRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return req, false
+	}
+	return req, true
+}
+
+// overrideErrorStatus is the status answering an override that was refused
+func overrideErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrWrongPIN):
+		return http.StatusForbidden
+	case errors.Is(err, ErrPINLocked), errors.Is(err, ErrOverrideLimit), errors.Is(err, ErrNoSnoozesLeft):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (s *ControlServer) deleteOverride(w http.ResponseWriter, r *http.Request) {
+	if !s.engine.ProcessPolicy().Revoke(r.PathValue("app")) {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("no override for %q", r.PathValue("app")))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *ControlServer) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error(fmt.Sprintf("Error writing control API response: %v", err))
+	}
+}
+
+func (s *ControlServer) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, map[string]string{"error": err.Error()})
+}
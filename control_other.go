@@ -0,0 +1,11 @@
+//go:build !unix
+
+package sleego
+
+import "net"
+
+// listenPrivate listens on a Unix socket. There is no umask to narrow here,
+// so ListenControl restricts its permissions right after it is created.
+func listenPrivate(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,373 @@
+package sleego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaogabriel01/sleego/clock/fake"
+)
+
+var controlTestNow = time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+
+func newTestControlServer(t *testing.T, config FileConfig, reload func() error, processes ...Process) (*ControlServer, *Engine) {
+	t.Helper()
+	engine, _ := newTestEngine(t, config,
+		WithMonitor(&MockProcessorMonitor{processes: processes}),
+		WithProcessPolicyOptions(WithClock(fake.NewClock(controlTestNow))),
+	)
+	server, err := NewControlServer(engine, reload)
+	if err != nil {
+		t.Fatalf("NewControlServer() error = %v", err)
+	}
+	return server, engine
+}
+
+// request sends a request to the server and decodes the JSON response into out, if set
+func request(t *testing.T, server http.Handler, method, target, body string, out any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("Invalid JSON response %q: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+var controlTestConfig = FileConfig{
+	Apps:     []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}},
+	Shutdown: "23:00",
+	Notifiers: []NotifierConfig{
+		{Type: "webhook", URL: "https://example.com/hook", Headers: map[string]string{"Authorization": "Bearer secret"}},
+	},
+}
+
+func TestControlServer_Config(t *testing.T) {
+	server, _ := newTestControlServer(t, controlTestConfig, nil)
+
+	var got FileConfig
+	if status := request(t, server, "GET", "/v1/config", "", &got); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(got.Apps) != 1 || got.Apps[0].Name != "game" || got.Shutdown != "23:00" {
+		t.Errorf("Unexpected config %+v", got)
+	}
+	if header := got.Notifiers[0].Headers["Authorization"]; header != redacted {
+		t.Errorf("Expected the webhook header to be redacted, got %q", header)
+	}
+}
+
+func TestControlServer_Processes(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 701}}
+	server, _ := newTestControlServer(t, controlTestConfig, nil, game)
+
+	var got []MatchedProcess
+	if status := request(t, server, "GET", "/v1/processes", "", &got); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(got) != 1 || got[0].Pid != 701 || got[0].Allowed {
+		t.Errorf("Expected the blocked game, got %+v", got)
+	}
+}
+
+func TestControlServer_Shutdown(t *testing.T) {
+	server, _ := newTestControlServer(t, controlTestConfig, nil)
+
+	var got shutdownStatus
+	request(t, server, "GET", "/v1/shutdown", "", &got)
+	if want := time.Date(2023, 10, 10, 23, 0, 0, 0, time.UTC); !got.Scheduled.Equal(want) {
+		t.Errorf("Expected the shutdown at %v, got %v", want, got.Scheduled)
+	}
+}
+
+func TestControlServer_Events(t *testing.T) {
+	server, engine := newTestControlServer(t, controlTestConfig, nil)
+	for pid := 1; pid <= 3; pid++ {
+		engine.recent.add(Event{Kind: EventProcessKilled, Pid: pid})
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantPids   []int
+	}{
+		{name: "all", target: "/v1/events", wantStatus: http.StatusOK, wantPids: []int{1, 2, 3}},
+		{name: "limited", target: "/v1/events?limit=1", wantStatus: http.StatusOK, wantPids: []int{3}},
+		{name: "invalid limit", target: "/v1/events?limit=many", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var events []Event
+			json.Unmarshal(rec.Body.Bytes(), &events)
+			if len(events) != len(tt.wantPids) {
+				t.Fatalf("Expected %d events, got %+v", len(tt.wantPids), events)
+			}
+			for i, pid := range tt.wantPids {
+				if events[i].Pid != pid {
+					t.Errorf("Expected event %d to have PID %d, got %d", i, pid, events[i].Pid)
+				}
+			}
+		})
+	}
+}
+
+func TestControlServer_Reload(t *testing.T) {
+	tests := []struct {
+		name       string
+		reload     func() error
+		wantStatus int
+	}{
+		{name: "reloaded", reload: func() error { return nil }, wantStatus: http.StatusNoContent},
+		{name: "invalid config", reload: func() error { return errors.New("invalid config") }, wantStatus: http.StatusBadRequest},
+		{name: "not available", wantStatus: http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newTestControlServer(t, controlTestConfig, tt.reload)
+			if status := request(t, server, "POST", "/v1/reload", "", nil); status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, status)
+			}
+		})
+	}
+}
+
+func TestControlServer_Overrides(t *testing.T) {
+	server, _ := newTestControlServer(t, controlTestConfig, nil)
+
+	var granted Override
+	if status := request(t, server, "POST", "/v1/overrides", `{"app": "game", "minutes": 30}`, &granted); status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", status)
+	}
+	if want := controlTestNow.Add(30 * time.Minute); granted.App != "game" || !granted.Until.Equal(want) {
+		t.Errorf("Unexpected override %+v", granted)
+	}
+
+	var overrides []Override
+	request(t, server, "GET", "/v1/overrides", "", &overrides)
+	if len(overrides) != 1 || overrides[0].App != "game" {
+		t.Errorf("Expected the granted override, got %+v", overrides)
+	}
+
+	if status := request(t, server, "POST", "/v1/overrides", `{"app": "browser", "minutes": 30}`, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected an unknown app to be rejected, got status %d", status)
+	}
+	if status := request(t, server, "POST", "/v1/overrides", `not json`, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected an invalid body to be rejected, got status %d", status)
+	}
+	if status := request(t, server, "DELETE", "/v1/overrides/game", "", nil); status != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", status)
+	}
+	if status := request(t, server, "DELETE", "/v1/overrides/game", "", nil); status != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing override, got %d", status)
+	}
+}
+
+func TestControlServer_OverrideErrors(t *testing.T) {
+	config := controlTestConfig
+	config.Overrides = OverrideConfig{PinHash: testPINHash(t, "1234")}
+	server, _ := newTestControlServer(t, config, nil)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "missing PIN", body: `{"app": "game", "minutes": 30}`, wantStatus: http.StatusForbidden},
+		{name: "wrong PIN", body: `{"app": "game", "minutes": 30, "pin": "0000"}`, wantStatus: http.StatusForbidden},
+		{name: "right PIN", body: `{"app": "game", "minutes": 30, "pin": "1234"}`, wantStatus: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := request(t, server, "POST", "/v1/overrides", tt.body, nil); status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, status)
+			}
+		})
+	}
+
+	var got FileConfig
+	request(t, server, "GET", "/v1/config", "", &got)
+	if got.Overrides.PinHash != redacted {
+		t.Errorf("Expected the PIN hash to be redacted, got %q", got.Overrides.PinHash)
+	}
+}
+
+func TestControlServer_PostponeShutdown(t *testing.T) {
+	clock := fake.NewClock(controlTestNow)
+	policy, _ := newPostponableShutdownPolicy(clock, nil, nil)
+	engine, _ := newTestEngine(t, controlTestConfig,
+		WithShutdownPolicy(policy),
+		WithProcessPolicyOptions(WithClock(clock), WithTicks(make(chan time.Time))),
+	)
+	server, err := NewControlServer(engine, nil)
+	if err != nil {
+		t.Fatalf("NewControlServer() error = %v", err)
+	}
+	if status := request(t, server, "POST", "/v1/shutdown/postpone", `{"minutes": 60}`, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 before the shutdown is scheduled, got %d", status)
+	}
+
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer engine.Stop()
+	clock.BlockUntil(1)
+
+	var got shutdownStatus
+	if status := request(t, server, "POST", "/v1/shutdown/postpone", `{"minutes": 60}`, &got); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if want := time.Date(2023, 10, 11, 0, 0, 0, 0, time.UTC); !got.Scheduled.Equal(want) {
+		t.Errorf("Expected the shutdown to move to %v, got %v", want, got.Scheduled)
+	}
+}
+
+func TestControlServer_SnoozeShutdown(t *testing.T) {
+	config := controlTestConfig
+	config.Snooze = SnoozeConfig{Count: 1, Minutes: 10}
+	clock := fake.NewClock(controlTestNow)
+	policy, _ := newPostponableShutdownPolicy(clock, nil, nil)
+	engine, _ := newTestEngine(t, config,
+		WithShutdownPolicy(policy),
+		WithProcessPolicyOptions(WithClock(clock), WithTicks(make(chan time.Time))),
+	)
+	server, err := NewControlServer(engine, nil)
+	if err != nil {
+		t.Fatalf("NewControlServer() error = %v", err)
+	}
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer engine.Stop()
+	clock.BlockUntil(1)
+
+	var got shutdownStatus
+	request(t, server, "GET", "/v1/shutdown", "", &got)
+	if got.SnoozesLeft != 1 {
+		t.Errorf("Expected 1 snooze left, got %d", got.SnoozesLeft)
+	}
+	if status := request(t, server, "POST", "/v1/shutdown/snooze", "", &got); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if want := time.Date(2023, 10, 10, 23, 10, 0, 0, time.UTC); !got.Scheduled.Equal(want) || got.SnoozesLeft != 0 {
+		t.Errorf("Expected the shutdown at %v with no snooze left, got %+v", want, got)
+	}
+	if status := request(t, server, "POST", "/v1/shutdown/snooze", "", nil); status != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 once the snoozes are used up, got %d", status)
+	}
+}
+
+func TestListenControl_ServesOnUnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sleego")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sleego.sock")
+	// A socket left behind by a crashed run is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := ListenControl(path, "")
+	if err != nil {
+		t.Fatalf("ListenControl() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected socket permissions 0600, got %o", perm)
+	}
+
+	server, _ := newTestControlServer(t, controlTestConfig, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- server.Serve(ctx, listener) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://sleego/v1/shutdown")
+	if err != nil {
+		t.Fatalf("Request over the socket failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket to be removed, got %v", err)
+	}
+}
+
+func TestListenControl_RefusesSocketInUse(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sleego")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sleego.sock")
+
+	running, err := ListenControl(path, "")
+	if err != nil {
+		t.Fatalf("ListenControl() error = %v", err)
+	}
+	defer running.Close()
+	go func() {
+		for {
+			conn, err := running.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	if second, err := ListenControl(path, ""); err == nil {
+		second.Close()
+		t.Fatal("Expected ListenControl() to refuse a socket another instance listens on")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the running instance to keep its socket, got %v", err)
+	}
+}
+
+func TestListenControl_RefusesToReplaceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenControl(path, ""); err == nil {
+		t.Error("Expected ListenControl() to refuse replacing a regular file")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package sleego
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenPrivate listens on a Unix socket that is created accessible to its
+// owner only, so nobody else can connect before its permissions are set. The
+// umask is process wide, but narrowing it only drops the group and other
+// permissions of files other goroutines create meanwhile, such as the state.
+func listenPrivate(path string) (net.Listener, error) {
+	old := syscall.Umask(0077)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
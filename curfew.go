@@ -0,0 +1,88 @@
+package sleego
+
+import (
+	"fmt"
+	"time"
+)
+
+// Time the machine may stay on when it is used during the curfew, by default
+// and at the least and most
+const (
+	defaultCurfewGrace = 5 * time.Minute
+	minCurfewGrace     = time.Minute
+	maxCurfewGrace     = time.Hour
+)
+
+// CurfewEnforcer is implemented by shutdown policies that shut the machine
+// down again when it is used during a curfew
+type CurfewEnforcer interface {
+	// SetCurfew configures the curfew, which is disabled when cfg.From is empty
+	SetCurfew(cfg CurfewConfig) error
+}
+
+// CurfewConfig is a daily period during which the machine must stay off
+type CurfewConfig struct {
+	// From and To are the times of day (HH:MM) the curfew starts and ends at.
+	// A curfew whose end is before its start runs past midnight.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+
+	// Grace is how long the machine may stay on once used during the curfew,
+	// as a duration such as "5m"
+	Grace string `json:"grace,omitempty"`
+}
+
+// curfew is a parsed CurfewConfig
+type curfew struct {
+	window TimeWindow
+	grace  time.Duration
+}
+
+// parseCurfew parses cfg, returning nil when no curfew is configured
+func parseCurfew(cfg CurfewConfig) (*curfew, error) {
+	if cfg.From == "" && cfg.To == "" {
+		return nil, nil
+	}
+	c := &curfew{window: TimeWindow{AllowedFrom: cfg.From, AllowedTo: cfg.To}, grace: defaultCurfewGrace}
+	if _, _, err := windowBounds(c.window, time.Now()); err != nil {
+		return nil, fmt.Errorf("invalid curfew: %w", err)
+	}
+	if cfg.Grace != "" {
+		grace, err := time.ParseDuration(cfg.Grace)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing curfew grace: %w", err)
+		}
+		c.grace = grace
+	}
+	return c, nil
+}
+
+// period returns the bounds of the curfew in effect at now, false when now is
+// outside the curfew or there is none
+func (c *curfew) period(now time.Time) (time.Time, time.Time, bool) {
+	if c == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	// A curfew in effect started today or, running past midnight, yesterday
+	for _, day := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		start, end, err := windowBounds(c.window, day)
+		if err == nil && !now.Before(start) && now.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// SetCurfew configures the curfew checked whenever a shutdown is scheduled
+func (s *ShutdownPolicyImpl) SetCurfew(cfg CurfewConfig) error {
+	c, err := parseCurfew(cfg)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.curfew = c
+	return nil
+}
+
+var _ CurfewEnforcer = &ShutdownPolicyImpl{}
@@ -0,0 +1,135 @@
+package sleego
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/joaogabriel01/sleego/clock/fake"
+)
+
+func TestCurfewPeriod(t *testing.T) {
+	day := func(d, hour, min int) time.Time { return time.Date(2023, 10, d, hour, min, 0, 0, time.UTC) }
+	tests := []struct {
+		name      string
+		cfg       CurfewConfig
+		now       time.Time
+		wantOK    bool
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{name: "after midnight", cfg: CurfewConfig{From: "23:00", To: "06:00"}, now: day(11, 0, 30), wantOK: true, wantStart: day(10, 23, 0), wantEnd: day(11, 6, 0)},
+		{name: "before midnight", cfg: CurfewConfig{From: "23:00", To: "06:00"}, now: day(10, 23, 30), wantOK: true, wantStart: day(10, 23, 0), wantEnd: day(11, 6, 0)},
+		{name: "at the end", cfg: CurfewConfig{From: "23:00", To: "06:00"}, now: day(11, 6, 0)},
+		{name: "during the day", cfg: CurfewConfig{From: "23:00", To: "06:00"}, now: day(11, 12, 0)},
+		{name: "within the same day", cfg: CurfewConfig{From: "13:00", To: "15:00"}, now: day(11, 14, 0), wantOK: true, wantStart: day(11, 13, 0), wantEnd: day(11, 15, 0)},
+		{name: "no curfew", now: day(11, 0, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCurfew(tt.cfg)
+			if err != nil {
+				t.Fatalf("parseCurfew() error = %v", err)
+			}
+			start, end, ok := c.period(tt.now)
+			if ok != tt.wantOK || !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("period() = %v, %v, %v, want %v, %v, %v", start, end, ok, tt.wantStart, tt.wantEnd, tt.wantOK)
+			}
+		})
+	}
+}
+
+// curfewTestNow is during the curfew of curfewTestConfig, after its shutdown
+var curfewTestNow = time.Date(2023, 10, 11, 0, 10, 0, 0, time.UTC)
+
+var curfewTestConfig = CurfewConfig{From: "23:59", To: "06:00", Grace: "5m"}
+
+func newCurfewShutdownPolicy(t *testing.T, clock *fake.Clock, events *EventBus, timesToAlert []int) (*ShutdownPolicyImpl, <-chan time.Time) {
+	t.Helper()
+	policy, shutdowns := newPostponableShutdownPolicy(clock, events, timesToAlert)
+	if err := policy.SetCurfew(curfewTestConfig); err != nil {
+		t.Fatalf("SetCurfew() error = %v", err)
+	}
+	return policy, shutdowns
+}
+
+func TestShutdownPolicyImpl_CurfewShutsDownAfterGrace(t *testing.T) {
+	clock := fake.NewClock(curfewTestNow)
+	events, ch := newTestBus(10)
+	policy, shutdowns := newCurfewShutdownPolicy(t, clock, events, []int{1})
+	endTime := time.Date(0, 1, 1, 23, 59, 0, 0, time.UTC)
+
+	done := applyAsync(context.Background(), policy, endTime)
+	event := <-ch
+	if event.Kind != EventShutdownWarning || event.MinutesRemaining != 5 || event.Message != "Curfew until 06:00, shutting down in 5 minutes" {
+		t.Errorf("Unexpected curfew warning %+v", event)
+	}
+	clock.BlockUntil(2)
+	clock.Advance(4 * time.Minute)
+	if event := <-ch; event.Kind != EventShutdownWarning || event.MinutesRemaining != 1 {
+		t.Errorf("Expected the shutdown warning, got %+v", event)
+	}
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Errorf("Apply returned error: %v", err)
+	}
+	if at := <-shutdowns; !at.Equal(curfewTestNow.Add(5 * time.Minute)) {
+		t.Errorf("Shut down at %v, want after the grace period", at)
+	}
+}
+
+func TestShutdownPolicyImpl_CurfewSchedule(t *testing.T) {
+	endTime := time.Date(0, 1, 1, 23, 59, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		now           time.Time
+		endTime       time.Time
+		wantScheduled time.Time
+		wantCurfew    bool
+	}{
+		{name: "during the curfew", now: curfewTestNow, wantScheduled: curfewTestNow.Add(5 * time.Minute), wantCurfew: true},
+		{name: "outside the curfew", now: time.Date(2023, 10, 11, 12, 0, 0, 0, time.UTC), wantScheduled: time.Date(2023, 10, 11, 23, 59, 0, 0, time.UTC)},
+		// The daily shutdown is due before the grace period ends
+		{name: "shutdown within the grace period", now: curfewTestNow, endTime: time.Date(0, 1, 1, 0, 12, 0, 0, time.UTC), wantScheduled: curfewTestNow.Add(2 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, _ := newCurfewShutdownPolicy(t, fake.NewClock(tt.now), nil, nil)
+			endTime := endTime
+			if !tt.endTime.IsZero() {
+				endTime = tt.endTime
+			}
+			_, scheduled, curfewEnd := policy.schedule(tt.now, endTime)
+			if !scheduled.Equal(tt.wantScheduled) {
+				t.Errorf("Scheduled at %v, want %v", scheduled, tt.wantScheduled)
+			}
+			if curfewEnd.IsZero() == tt.wantCurfew {
+				t.Errorf("Curfew end %v, want curfew: %v", curfewEnd, tt.wantCurfew)
+			}
+		})
+	}
+}
+
+func TestShutdownPolicyImpl_CurfewKeepsPostponement(t *testing.T) {
+	clock := fake.NewClock(curfewTestNow)
+	policy, _ := newCurfewShutdownPolicy(t, clock, nil, nil)
+	policy.SetSnooze(SnoozeConfig{Count: 1, Minutes: 10})
+	endTime := time.Date(0, 1, 1, 23, 59, 0, 0, time.UTC)
+
+	run, _, _ := policy.schedule(curfewTestNow, endTime)
+	if left := policy.SnoozesLeft(); left != 0 {
+		t.Errorf("SnoozesLeft() = %d during the curfew, want 0", left)
+	}
+	postponed, err := policy.Postpone(30 * time.Minute)
+	if err != nil {
+		t.Fatalf("Postpone() error = %v", err)
+	}
+	policy.unschedule(run)
+
+	// Apply restarting, e.g. on a reload, must not bring the shutdown forward
+	clock.Advance(10 * time.Minute)
+	_, scheduled, curfewEnd := policy.schedule(clock.Now(), endTime)
+	if !scheduled.Equal(postponed) || !curfewEnd.IsZero() {
+		t.Errorf("Scheduled at %v with curfew end %v, want the postponed %v", scheduled, curfewEnd, postponed)
+	}
+}
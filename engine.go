@@ -0,0 +1,555 @@
+package sleego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joaogabriel01/sleego/internal/logger"
+)
+
+// Engine runs the process and shutdown policies for a configuration and
+// applies new configurations to them while they run
+type Engine struct {
+	monitor          ProcessorMonitor
+	categoryOperator CategoryOperator
+	events           *EventBus
+	processOpts      []ProcessPolicyOption
+	shutdownOpts     []ShutdownPolicyOption
+	processPolicy    *ProcessPolicyImpl
+	shutdownPolicy   ShutdownPolicy
+	extraNotifiers   []Notifier
+	logger           logger.Logger
+
+	mu             sync.Mutex
+	config         FileConfig
+	ctx            context.Context
+	cancel         context.CancelFunc
+	stopping       bool
+	wg             sync.WaitGroup
+	err            error
+	done           chan struct{}
+	shutdown       string
+	curfew         CurfewConfig
+	cancelShutdown context.CancelFunc
+	notifiers      []Notifier
+	stopNotifiers  []func()
+	recent         *eventLog
+	guard          *overrideGuard
+}
+
+// Number of events kept for RecentEvents
+const recentEventsSize = 100
+
+// EngineOption configures optional behavior of an Engine
+type EngineOption func(*Engine)
+
+// WithMonitor replaces the monitor of the running processes
+func WithMonitor(monitor ProcessorMonitor) EngineOption {
+	return func(e *Engine) {
+		e.monitor = monitor
+	}
+}
+
+// WithCategoryOperator replaces the global category operator
+func WithCategoryOperator(categoryOperator CategoryOperator) EngineOption {
+	return func(e *Engine) {
+		e.categoryOperator = categoryOperator
+	}
+}
+
+// WithProcessPolicyOptions adds options to the process policy, after the
+// ones derived from the configuration
+func WithProcessPolicyOptions(opts ...ProcessPolicyOption) EngineOption {
+	return func(e *Engine) {
+		e.processOpts = append(e.processOpts, opts...)
+	}
+}
+
+// WithShutdownPolicyOptions adds options to the shutdown policy
+func WithShutdownPolicyOptions(opts ...ShutdownPolicyOption) EngineOption {
+	return func(e *Engine) {
+		e.shutdownOpts = append(e.shutdownOpts, opts...)
+	}
+}
+
+// WithShutdownPolicy replaces the shutdown policy, whose options are then ignored
+func WithShutdownPolicy(policy ShutdownPolicy) EngineOption {
+	return func(e *Engine) {
+		e.shutdownPolicy = policy
+	}
+}
+
+// WithNotifiers adds notifiers to the ones selected in the configuration
+func WithNotifiers(notifiers ...Notifier) EngineOption {
+	return func(e *Engine) {
+		e.extraNotifiers = append(e.extraNotifiers, notifiers...)
+	}
+}
+
+// NewEngine validates the configuration and creates the policies for it.
+// Nothing runs until Start is called.
+func NewEngine(config FileConfig, opts ...EngineOption) (*Engine, error) {
+	logger, err := logger.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logger: %w", err)
+	}
+	if err := ValidateConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	e := &Engine{logger: logger, config: config, events: NewEventBus(), done: make(chan struct{}), recent: newEventLog(recentEventsSize)}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.monitor == nil {
+		e.monitor = &ProcessorMonitorImpl{}
+	}
+	if e.categoryOperator == nil {
+		e.categoryOperator = GetCategoryOperator()
+	}
+
+	processOpts, err := configPolicyOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	if e.notifiers, err = e.buildNotifiers(config); err != nil {
+		return nil, err
+	}
+	processOpts = append(processOpts, e.processOpts...)
+	e.processPolicy = NewProcessPolicyImpl(e.monitor, e.categoryOperator, nil, e.events, processOpts...)
+	e.processPolicy.SetApps(config.Apps)
+	if e.guard, err = newOverrideGuard(config.Overrides, e.processPolicy.usage.periodStart); err != nil {
+		return nil, err
+	}
+	if e.shutdownPolicy == nil {
+		e.shutdownPolicy = NewShutdownPolicyImpl(e.events, config.ShutdownWarnings, e.shutdownOpts...)
+	}
+	if err := e.configureShutdownPolicy(config); err != nil {
+		return nil, err
+	}
+	e.categoryOperator.SetProcessByCategories(config.Categories)
+	return e, nil
+}
+
+// configPolicyOptions derives the process policy options set in the configuration
+func configPolicyOptions(config FileConfig) ([]ProcessPolicyOption, error) {
+	var opts []ProcessPolicyOption
+	if config.QuotaReset != "" {
+		quotaReset, err := time.Parse(configTimeLayout, config.QuotaReset)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing quota reset time: %w", err)
+		}
+		opts = append(opts, WithQuotaReset(quotaReset))
+	}
+	pollInterval, err := parsePollInterval(config.PollInterval)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, WithPollInterval(pollInterval))
+	if len(config.KillWarnings) != 0 {
+		opts = append(opts, WithKillWarnings(config.KillWarnings))
+	}
+	opts = append(opts, WithProtected(config.Protected))
+	return opts, nil
+}
+
+// parsePollInterval parses the configured poll interval, zero when unset
+func parsePollInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing poll interval: %w", err)
+	}
+	return interval, nil
+}
+
+// Start runs the policies until ctx is done, Stop is called or a policy fails
+func (e *Engine) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ctx != nil {
+		return errors.New("engine already started")
+	}
+	e.ctx, e.cancel = context.WithCancel(ctx)
+
+	// Subscribe before any policy runs so no event is missed
+	e.startNotifiersLocked()
+	events, unsubscribe := e.events.Subscribe(0)
+	e.goLocked(e.ctx, func(ctx context.Context) error {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case event := <-events:
+				e.recent.add(event)
+			}
+		}
+	})
+
+	apps := e.config.Apps
+	e.goLocked(e.ctx, func(ctx context.Context) error {
+		return e.processPolicy.Apply(ctx, apps)
+	})
+	shutdownTime, err := parseShutdownTime(e.config.Shutdown)
+	if err != nil {
+		e.cancel()
+		return err
+	}
+	e.scheduleShutdownLocked(e.config.Shutdown, shutdownTime, e.config.Curfew)
+
+	go func() {
+		<-e.ctx.Done()
+		e.mu.Lock()
+		e.stopping = true
+		e.mu.Unlock()
+		e.wg.Wait()
+		close(e.done)
+	}()
+	return nil
+}
+
+// Stop stops the policies and waits for them to return
+func (e *Engine) Stop() {
+	e.mu.Lock()
+	cancel := e.cancel
+	e.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-e.done
+}
+
+// Wait blocks until the engine has stopped and returns the error of the
+// policy that failed, if any. It returns nil once the engine was stopped
+// through its context or Stop.
+func (e *Engine) Wait() error {
+	e.mu.Lock()
+	started := e.ctx != nil
+	e.mu.Unlock()
+	if !started {
+		return errors.New("engine not started")
+	}
+	<-e.done
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Reload validates a new configuration and applies it to the running
+// policies. When it is invalid nothing is changed.
+func (e *Engine) Reload(config FileConfig) error {
+	if err := ValidateConfig(config); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	pollInterval, err := parsePollInterval(config.PollInterval)
+	if err != nil {
+		return err
+	}
+	var quotaReset time.Time
+	if config.QuotaReset != "" {
+		if quotaReset, err = time.Parse(configTimeLayout, config.QuotaReset); err != nil {
+			return fmt.Errorf("error parsing quota reset time: %w", err)
+		}
+	}
+	shutdownTime, err := parseShutdownTime(config.Shutdown)
+	if err != nil {
+		return err
+	}
+	notifiers, err := e.buildNotifiers(config)
+	if err != nil {
+		return err
+	}
+
+	// Everything the config can be rejected for is checked above, so a
+	// rejected config leaves the engine as it was
+	if err := e.guard.configure(config.Overrides); err != nil {
+		return err
+	}
+	if err := e.configureShutdownPolicy(config); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ctx != nil {
+		e.scheduleShutdownLocked(config.Shutdown, shutdownTime, config.Curfew)
+	}
+	e.categoryOperator.SetProcessByCategories(config.Categories)
+	e.processPolicy.SetPollInterval(pollInterval)
+	e.processPolicy.SetQuotaReset(quotaReset)
+	e.processPolicy.SetKillWarnings(config.KillWarnings)
+	e.processPolicy.SetProtected(config.Protected)
+	e.processPolicy.SetApps(config.Apps)
+	e.notifiers = notifiers
+	if e.ctx != nil {
+		e.startNotifiersLocked()
+	}
+	e.config = config
+	return nil
+}
+
+func (e *Engine) buildNotifiers(config FileConfig) ([]Notifier, error) {
+	notifiers, err := NewNotifiers(config.Notifiers)
+	if err != nil {
+		return nil, err
+	}
+	return append(notifiers, e.extraNotifiers...), nil
+}
+
+// startNotifiersLocked replaces the running notifiers with the configured
+// ones. Each has its own subscription, so a slow notifier such as a webhook
+// that retries only delays its own deliveries.
+func (e *Engine) startNotifiersLocked() {
+	for _, stop := range e.stopNotifiers {
+		stop()
+	}
+	e.stopNotifiers = nil
+	for _, notifier := range e.notifiers {
+		events, unsubscribe := e.events.Subscribe(0)
+		e.stopNotifiers = append(e.stopNotifiers, unsubscribe)
+		e.goLocked(e.ctx, func(ctx context.Context) error {
+			e.notify(ctx, notifier, events)
+			return nil
+		})
+	}
+}
+
+// notify delivers events to the notifier until ctx is done or the
+// subscription ends. Failures are only logged.
+func (e *Engine) notify(ctx context.Context, notifier Notifier, events <-chan Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := notifier.Notify(ctx, event); err != nil {
+				e.logger.Error(fmt.Sprintf("Error notifying %s event: %v", event.Kind, err))
+			}
+		}
+	}
+}
+
+// Config returns the configuration currently applied
+func (e *Engine) Config() FileConfig {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.config
+}
+
+// Events returns the bus on which both policies publish their events
+func (e *Engine) Events() *EventBus {
+	return e.events
+}
+
+// RecentEvents returns up to limit of the latest events published since
+// Start, oldest first. A limit that is not positive returns all kept.
+func (e *Engine) RecentEvents(limit int) []Event {
+	return e.recent.recent(limit)
+}
+
+// NextShutdown returns when the configured shutdown happens next, false
+// when none is configured
+func (e *Engine) NextShutdown() (time.Time, bool) {
+	if postponer, ok := e.shutdownPolicy.(ShutdownPostponer); ok {
+		if scheduled, ok := postponer.Scheduled(); ok {
+			return scheduled, true
+		}
+	}
+	e.mu.Lock()
+	shutdown := e.config.Shutdown
+	e.mu.Unlock()
+	if shutdown == "" {
+		return time.Time{}, false
+	}
+	endTime, err := time.Parse(configTimeLayout, shutdown)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return nextShutdownTime(e.processPolicy.now(), endTime), true
+}
+
+// Grant overrides the rule named app for d, if pin is right and the daily
+// cap of overrides isn't reached
+func (e *Engine) Grant(app string, d time.Duration, pin string) (Override, error) {
+	var override Override
+	err := e.guard.grant(e.processPolicy.now(), pin, func() (err error) {
+		override, err = e.processPolicy.Grant(app, d)
+		return err
+	})
+	if err != nil {
+		e.denied(app, err)
+	}
+	return override, err
+}
+
+// PostponeShutdown delays the pending shutdown by d, if pin is right and the
+// daily cap of overrides isn't reached, and returns its new time
+func (e *Engine) PostponeShutdown(d time.Duration, pin string) (time.Time, error) {
+	postponer, ok := e.shutdownPolicy.(ShutdownPostponer)
+	if !ok {
+		return time.Time{}, errors.New("the shutdown policy can't be postponed")
+	}
+	var scheduled time.Time
+	err := e.guard.grant(e.processPolicy.now(), pin, func() (err error) {
+		scheduled, err = postponer.Postpone(d)
+		return err
+	})
+	if err != nil {
+		e.denied("", err)
+	}
+	return scheduled, err
+}
+
+// Snooze delays the pending shutdown by one of the snoozes allowed in the
+// configuration and returns its new time
+func (e *Engine) Snooze() (time.Time, error) {
+	snoozer, ok := e.shutdownPolicy.(ShutdownSnoozer)
+	if !ok {
+		return time.Time{}, errors.New("the shutdown policy can't be snoozed")
+	}
+	return snoozer.Snooze()
+}
+
+// SnoozesLeft returns how many more times the pending shutdown can be snoozed
+func (e *Engine) SnoozesLeft() int {
+	if snoozer, ok := e.shutdownPolicy.(ShutdownSnoozer); ok {
+		return snoozer.SnoozesLeft()
+	}
+	return 0
+}
+
+// denied reports an override refused for its PIN or the daily cap
+func (e *Engine) denied(app string, err error) {
+	if !errors.Is(err, ErrWrongPIN) && !errors.Is(err, ErrPINLocked) && !errors.Is(err, ErrOverrideLimit) {
+		return
+	}
+	target := app
+	if target == "" {
+		target = "shutdown"
+	}
+	msg := fmt.Sprintf("Override denied for %s: %v", target, err)
+	e.logger.Info(msg)
+	e.events.Publish(Event{Kind: EventOverrideDenied, Time: e.processPolicy.now(), App: app, Error: err.Error(), Message: msg})
+}
+
+// ProcessPolicy returns the process policy run by the engine
+func (e *Engine) ProcessPolicy() *ProcessPolicyImpl {
+	return e.processPolicy
+}
+
+// configureShutdownPolicy applies the warnings, snooze, curfew and power action of
+// config to the shutdown policy, if it supports them
+func (e *Engine) configureShutdownPolicy(config FileConfig) error {
+	if warner, ok := e.shutdownPolicy.(ShutdownWarner); ok {
+		warner.SetShutdownWarnings(config.ShutdownWarnings)
+	}
+	if snoozer, ok := e.shutdownPolicy.(ShutdownSnoozer); ok {
+		if err := snoozer.SetSnooze(config.Snooze); err != nil {
+			return err
+		}
+	}
+	if enforcer, ok := e.shutdownPolicy.(CurfewEnforcer); ok {
+		if err := enforcer.SetCurfew(config.Curfew); err != nil {
+			return err
+		}
+	}
+	if setter, ok := e.shutdownPolicy.(PowerActionSetter); ok {
+		if err := setter.SetPowerAction(config.PowerAction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseShutdownTime parses the configured shutdown time, which is zero when
+// no shutdown is configured
+func parseShutdownTime(shutdown string) (time.Time, error) {
+	if shutdown == "" {
+		return time.Time{}, nil
+	}
+	shutdownTime, err := time.Parse(configTimeLayout, shutdown)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing shutdown time: %w", err)
+	}
+	return shutdownTime, nil
+}
+
+// scheduleShutdownLocked restarts the shutdown policy at shutdownTime, parsed
+// from shutdown, when the shutdown time or the curfew changed, so a curfew
+// starting now is enforced
+func (e *Engine) scheduleShutdownLocked(shutdown string, shutdownTime time.Time, curfew CurfewConfig) {
+	if shutdown == e.shutdown && curfew == e.curfew && e.cancelShutdown != nil {
+		return
+	}
+
+	if e.cancelShutdown != nil {
+		e.cancelShutdown()
+	}
+	e.shutdown, e.curfew = shutdown, curfew
+	shutdownCtx, cancel := context.WithCancel(e.ctx)
+	e.cancelShutdown = cancel
+	if shutdown == "" {
+		return
+	}
+
+	e.logger.Info("Scheduling shutdown at " + shutdown)
+	e.goLocked(shutdownCtx, func(ctx context.Context) error {
+		return e.applyShutdown(ctx, shutdownTime)
+	})
+}
+
+// Time waited before running a failed shutdown policy again. The shutdown
+// time has passed by then, so the policy schedules the next day's shutdown.
+const shutdownRetryDelay = time.Minute
+
+// applyShutdown runs the shutdown policy until ctx is done. A failure is
+// reported without stopping the engine, so processes are still enforced.
+func (e *Engine) applyShutdown(ctx context.Context, endTime time.Time) error {
+	for {
+		err := e.shutdownPolicy.Apply(ctx, endTime)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		msg := fmt.Sprintf("Shutdown failed, retrying the next day: %v", err)
+		e.logger.Error(msg)
+		e.events.Publish(Event{Kind: EventShutdownFailed, Time: e.processPolicy.now(), Scheduled: endTime, Error: err.Error(), Message: msg})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-e.processPolicy.clock.After(shutdownRetryDelay):
+		}
+	}
+}
+
+// goLocked runs a policy in the background. An error it returns before its
+// context is done stops the whole engine.
+func (e *Engine) goLocked(ctx context.Context, run func(ctx context.Context) error) {
+	if e.stopping {
+		return
+	}
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		err := run(ctx)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		e.logger.Error(fmt.Sprintf("Policy failed, stopping: %v", err))
+		e.mu.Lock()
+		if e.err == nil {
+			e.err = err
+		}
+		e.mu.Unlock()
+		e.cancel()
+	}()
+}
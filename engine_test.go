@@ -0,0 +1,446 @@
+package sleego
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/joaogabriel01/sleego/clock/fake"
+)
+
+// recordingShutdownPolicy reports each scheduled shutdown and waits for its
+// context, or returns err right away when set
+type recordingShutdownPolicy struct {
+	scheduled chan time.Time
+	err       error
+}
+
+func (r *recordingShutdownPolicy) Apply(ctx context.Context, endTime time.Time) error {
+	r.scheduled <- endTime
+	if r.err != nil {
+		return r.err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type recordingCategoryOperator struct {
+	categories map[string][]string
+}
+
+func (r *recordingCategoryOperator) GetCategoriesOf(_ string) []string {
+	return nil
+}
+
+func (r *recordingCategoryOperator) SetProcessByCategories(categories map[string][]string) {
+	r.categories = categories
+}
+
+func newTestEngine(t *testing.T, config FileConfig, opts ...EngineOption) (*Engine, *recordingShutdownPolicy) {
+	t.Helper()
+	shutdownPolicy := &recordingShutdownPolicy{scheduled: make(chan time.Time, 2)}
+	opts = append([]EngineOption{
+		WithMonitor(&MockProcessorMonitor{}),
+		WithCategoryOperator(&recordingCategoryOperator{}),
+		WithShutdownPolicy(shutdownPolicy),
+	}, opts...)
+	engine, err := NewEngine(config, opts...)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	return engine, shutdownPolicy
+}
+
+func expectShutdownAt(t *testing.T, shutdownPolicy *recordingShutdownPolicy, hour, minute int) {
+	t.Helper()
+	select {
+	case endTime := <-shutdownPolicy.scheduled:
+		if endTime.Hour() != hour || endTime.Minute() != minute {
+			t.Errorf("Shutdown scheduled at %v, want %02d:%02d", endTime.Format("15:04"), hour, minute)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the shutdown to be scheduled")
+	}
+}
+
+func TestNewEngine_SetsCategoriesOnOperator(t *testing.T) {
+	categories := map[string][]string{"games": {"steam.exe"}}
+	categoryOp := &recordingCategoryOperator{}
+	newTestEngine(t, FileConfig{
+		Apps:       []AppConfig{{Name: "games", AllowedFrom: "09:00", AllowedTo: "17:00"}},
+		Categories: categories,
+	}, WithCategoryOperator(categoryOp))
+
+	if !reflect.DeepEqual(categoryOp.categories, categories) {
+		t.Errorf("SetProcessByCategories() categories = %v, want %v", categoryOp.categories, categories)
+	}
+}
+
+func TestNewEngine_RejectsInvalidConfig(t *testing.T) {
+	_, err := NewEngine(FileConfig{Apps: []AppConfig{{Name: "games", AllowedFrom: "25:00", AllowedTo: "17:00"}}})
+	if err == nil {
+		t.Fatal("Expected NewEngine() to reject an invalid config")
+	}
+}
+
+func TestEngine_StartAndStop(t *testing.T) {
+	engine, shutdownPolicy := newTestEngine(t, FileConfig{Shutdown: "23:00"})
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := engine.Start(context.Background()); err == nil {
+		t.Error("Expected a second Start() to fail")
+	}
+	expectShutdownAt(t, shutdownPolicy, 23, 0)
+
+	engine.Stop()
+	if err := engine.Wait(); err != nil {
+		t.Errorf("Wait() error = %v, want nil after Stop", err)
+	}
+}
+
+func TestEngine_StopsWhenContextDone(t *testing.T) {
+	engine, _ := newTestEngine(t, FileConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := engine.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	cancel()
+	done := make(chan error)
+	go func() {
+		done <- engine.Wait()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait() error = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the engine to stop once its context is done")
+	}
+}
+
+func TestEngine_RetriesFailedShutdown(t *testing.T) {
+	clock := fake.NewClock(time.Date(2023, 10, 10, 23, 0, 0, 0, time.UTC))
+	engine, shutdownPolicy := newTestEngine(t, FileConfig{Shutdown: "23:00"}, WithProcessPolicyOptions(WithClock(clock), WithTicks(make(chan time.Time))))
+	shutdownPolicy.err = errors.New("shutdown failed")
+	events, unsubscribe := engine.Events().Subscribe(10)
+	defer unsubscribe()
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer engine.Stop()
+
+	expectShutdownAt(t, shutdownPolicy, 23, 0)
+	for event := range events {
+		if event.Kind == EventShutdownFailed {
+			if event.Error != "shutdown failed" {
+				t.Errorf("Unexpected event %+v", event)
+			}
+			break
+		}
+	}
+	// The engine keeps running and tries the shutdown again
+	clock.BlockUntil(1)
+	clock.Advance(shutdownRetryDelay)
+	expectShutdownAt(t, shutdownPolicy, 23, 0)
+}
+
+func TestEngine_WaitBeforeStart(t *testing.T) {
+	engine, _ := newTestEngine(t, FileConfig{})
+	if err := engine.Wait(); err == nil {
+		t.Error("Expected Wait() to fail before Start()")
+	}
+}
+
+func TestEngine_ReloadSwapsConfig(t *testing.T) {
+	engine, shutdownPolicy := newTestEngine(t, FileConfig{Shutdown: "22:00"})
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer engine.Stop()
+	expectShutdownAt(t, shutdownPolicy, 22, 0)
+
+	apps := []AppConfig{{Name: "games", AllowedFrom: "09:00", AllowedTo: "17:00"}}
+	config := FileConfig{Apps: apps, Shutdown: "23:00", PollInterval: "2s"}
+	if err := engine.Reload(config); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	if got := engine.ProcessPolicy().Apps(); !reflect.DeepEqual(got, apps) {
+		t.Errorf("Apps() = %v, want %v", got, apps)
+	}
+	if got := engine.ProcessPolicy().PollInterval(); got != 2*time.Second {
+		t.Errorf("PollInterval() = %v, want 2s", got)
+	}
+	if got := engine.Config(); !reflect.DeepEqual(got, config) {
+		t.Errorf("Config() = %v, want %v", got, config)
+	}
+	expectShutdownAt(t, shutdownPolicy, 23, 0)
+
+	// An unchanged shutdown time is not rescheduled
+	if err := engine.Reload(config); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	select {
+	case endTime := <-shutdownPolicy.scheduled:
+		t.Errorf("Shutdown rescheduled at %v without a change", endTime.Format("15:04"))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEngine_ReloadSetsQuotaReset(t *testing.T) {
+	now := time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+	engine, _ := newTestEngine(t, FileConfig{QuotaReset: "04:00"}, WithProcessPolicyOptions(WithClock(fake.NewClock(now))))
+	policy := engine.ProcessPolicy()
+	policy.usage.add("games", time.Hour, now)
+
+	if err := engine.Reload(FileConfig{QuotaReset: "06:00"}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got, want := policy.usage.periodStart(now), time.Date(2023, 10, 10, 6, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Quota period starts at %v, want %v", got, want)
+	}
+	if used := policy.usage.get("games", now); used != time.Hour {
+		t.Errorf("Expected the usage of today to be kept, got %v", used)
+	}
+}
+
+func TestEngine_ReloadSetsKillWarnings(t *testing.T) {
+	engine, _ := newTestEngine(t, FileConfig{KillWarnings: []int{5}})
+	if err := engine.Reload(FileConfig{KillWarnings: []int{10, 1}}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := engine.ProcessPolicy().killWarnings; !reflect.DeepEqual(got, []int{10, 1}) {
+		t.Errorf("Kill warnings = %v, want [10 1]", got)
+	}
+}
+
+func TestEngine_RejectedReloadKeepsConfig(t *testing.T) {
+	config := FileConfig{Shutdown: "22:00", Overrides: OverrideConfig{MaxPerDay: 2}}
+	engine, _ := newTestEngine(t, config)
+
+	rejected := FileConfig{Shutdown: "25:00", Overrides: OverrideConfig{MaxPerDay: 5}}
+	if err := engine.Reload(rejected); err == nil {
+		t.Fatal("Expected Reload() to reject the config")
+	}
+	if !reflect.DeepEqual(engine.config, config) {
+		t.Errorf("Config = %+v, want %+v", engine.config, config)
+	}
+	if engine.guard.maxPerDay != 2 {
+		t.Errorf("Overrides per day = %d, want 2", engine.guard.maxPerDay)
+	}
+}
+
+func TestEngine_ReloadSetsShutdownWarnings(t *testing.T) {
+	engine, err := NewEngine(FileConfig{ShutdownWarnings: []int{5}},
+		WithMonitor(&MockProcessorMonitor{}),
+		WithCategoryOperator(&recordingCategoryOperator{}),
+	)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	policy := engine.shutdownPolicy.(*ShutdownPolicyImpl)
+	if got := policy.timesToAlert; !reflect.DeepEqual(got, []int{5}) {
+		t.Errorf("Shutdown warnings = %v, want [5]", got)
+	}
+
+	if err := engine.Reload(FileConfig{ShutdownWarnings: []int{10, 1}}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := policy.timesToAlert; !reflect.DeepEqual(got, []int{10, 1}) {
+		t.Errorf("Shutdown warnings = %v, want [10 1]", got)
+	}
+}
+
+func TestEngine_ReloadReschedulesForCurfew(t *testing.T) {
+	engine, shutdownPolicy := newTestEngine(t, FileConfig{Shutdown: "22:00"})
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer engine.Stop()
+	expectShutdownAt(t, shutdownPolicy, 22, 0)
+
+	// The same shutdown is scheduled again so a curfew in effect is enforced
+	if err := engine.Reload(FileConfig{Shutdown: "22:00", Curfew: CurfewConfig{From: "22:00", To: "06:00"}}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	expectShutdownAt(t, shutdownPolicy, 22, 0)
+}
+
+func TestEngine_ReloadKeepsConfigWhenInvalid(t *testing.T) {
+	apps := []AppConfig{{Name: "games", AllowedFrom: "09:00", AllowedTo: "17:00"}}
+	engine, _ := newTestEngine(t, FileConfig{Apps: apps})
+	engine.ProcessPolicy().SetApps(apps)
+
+	err := engine.Reload(FileConfig{Apps: []AppConfig{{Name: "games", AllowedFrom: "25:00", AllowedTo: "17:00"}}})
+	if err == nil {
+		t.Fatal("Expected Reload() to reject an invalid config")
+	}
+	if got := engine.ProcessPolicy().Apps(); !reflect.DeepEqual(got, apps) {
+		t.Errorf("Apps() = %v, want the previous %v", got, apps)
+	}
+	if got := engine.Config().Apps; !reflect.DeepEqual(got, apps) {
+		t.Errorf("Config().Apps = %v, want the previous %v", got, apps)
+	}
+}
+
+func TestEngine_DeliversEventsToNotifiers(t *testing.T) {
+	notifier := &recordingNotifier{seen: make(chan struct{}, 1)}
+	engine, _ := newTestEngine(t, FileConfig{}, WithNotifiers(notifier))
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer engine.Stop()
+
+	engine.Events().Publish(testEvent)
+	select {
+	case <-notifier.seen:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the notifier to receive the event")
+	}
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if notifier.events[0].Pid != testEvent.Pid {
+		t.Errorf("Unexpected event %+v", notifier.events[0])
+	}
+}
+
+// blockingNotifier never returns before its context is done, like a
+// webhook retrying an unreachable server
+type blockingNotifier struct{}
+
+func (blockingNotifier) Notify(ctx context.Context, _ Event) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestEngine_SlowNotifierDoesNotDelayOthers(t *testing.T) {
+	notifier := &recordingNotifier{seen: make(chan struct{}, 2)}
+	engine, _ := newTestEngine(t, FileConfig{}, WithNotifiers(blockingNotifier{}, notifier))
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer engine.Stop()
+
+	engine.Events().Publish(testEvent)
+	engine.Events().Publish(testEvent)
+	for range 2 {
+		select {
+		case <-notifier.seen:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the notifier to receive both events")
+		}
+	}
+}
+
+func TestEngine_ReloadRejectsInvalidNotifiers(t *testing.T) {
+	engine, _ := newTestEngine(t, FileConfig{})
+	if err := engine.Reload(FileConfig{Notifiers: []NotifierConfig{{Type: "file"}}}); err == nil {
+		t.Error("Expected Reload() to reject a file notifier without a path")
+	}
+}
+
+func TestEngine_RecentEvents(t *testing.T) {
+	engine, _ := newTestEngine(t, FileConfig{})
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer engine.Stop()
+
+	engine.Events().Publish(testEvent)
+	deadline := time.Now().Add(time.Second)
+	for len(engine.RecentEvents(0)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := engine.RecentEvents(0); len(got) != 1 || got[0].Pid != testEvent.Pid {
+		t.Errorf("RecentEvents() = %+v, want the published event", got)
+	}
+}
+
+func TestEngine_NextShutdown(t *testing.T) {
+	now := time.Date(2023, 10, 10, 22, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		shutdown string
+		want     time.Time
+		wantOk   bool
+	}{
+		{name: "later today", shutdown: "23:30", want: time.Date(2023, 10, 10, 23, 30, 0, 0, time.UTC), wantOk: true},
+		{name: "tomorrow", shutdown: "06:00", want: time.Date(2023, 10, 11, 6, 0, 0, 0, time.UTC), wantOk: true},
+		{name: "none", shutdown: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, _ := newTestEngine(t, FileConfig{Shutdown: tt.shutdown}, WithProcessPolicyOptions(WithClock(fake.NewClock(now))))
+			got, ok := engine.NextShutdown()
+			if ok != tt.wantOk || !got.Equal(tt.want) {
+				t.Errorf("NextShutdown() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestEngine_GrantChecksPIN(t *testing.T) {
+	config := FileConfig{
+		Apps:      []AppConfig{{Name: "browsers", AllowedFrom: "09:00", AllowedTo: "17:00"}},
+		Overrides: OverrideConfig{PinHash: testPINHash(t, "1234"), MaxPerDay: 1},
+	}
+	engine, _ := newTestEngine(t, config)
+	events, _ := engine.Events().Subscribe(4)
+
+	if _, err := engine.Grant("browsers", 30*time.Minute, "0000"); !errors.Is(err, ErrWrongPIN) {
+		t.Fatalf("Expected ErrWrongPIN, got %v", err)
+	}
+	if event := <-events; event.Kind != EventOverrideDenied || event.App != "browsers" || event.Error != ErrWrongPIN.Error() {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	if _, err := engine.Grant("browsers", 30*time.Minute, "1234"); err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+	if event := <-events; event.Kind != EventOverrideGranted {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	if _, err := engine.Grant("browsers", 30*time.Minute, "1234"); !errors.Is(err, ErrOverrideLimit) {
+		t.Errorf("Expected ErrOverrideLimit, got %v", err)
+	}
+}
+
+func TestEngine_PostponeShutdown(t *testing.T) {
+	now := time.Date(2023, 10, 10, 22, 0, 0, 0, time.UTC)
+	clock := fake.NewClock(now)
+	policy, _ := newPostponableShutdownPolicy(clock, nil, nil)
+	engine, _ := newTestEngine(t, FileConfig{Shutdown: "23:00"},
+		WithShutdownPolicy(policy),
+		WithProcessPolicyOptions(WithClock(clock), WithTicks(make(chan time.Time))),
+	)
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer engine.Stop()
+	clock.BlockUntil(1)
+
+	scheduled, err := engine.PostponeShutdown(time.Hour, "")
+	if err != nil {
+		t.Fatalf("PostponeShutdown() error = %v", err)
+	}
+	want := time.Date(2023, 10, 11, 0, 0, 0, 0, time.UTC)
+	if !scheduled.Equal(want) {
+		t.Errorf("PostponeShutdown() = %v, want %v", scheduled, want)
+	}
+	if got, _ := engine.NextShutdown(); !got.Equal(want) {
+		t.Errorf("NextShutdown() = %v, want the postponed %v", got, want)
+	}
+}
+
+func TestEngine_PostponeShutdownUnsupported(t *testing.T) {
+	engine, _ := newTestEngine(t, FileConfig{Shutdown: "23:00"})
+	if _, err := engine.PostponeShutdown(time.Hour, ""); err == nil {
+		t.Error("Expected a shutdown policy without Postpone to be refused")
+	}
+}
@@ -0,0 +1,149 @@
+package sleego
+
+import (
+	"sync"
+	"time"
+)
+
+// EventKind identifies what an Event reports
+type EventKind string
+
+const (
+	// EventKillWarning warns that a running process will be stopped at Scheduled
+	EventKillWarning EventKind = "kill_warning"
+	// EventProcessTerminating reports that a process was asked to exit and
+	// will be killed at Scheduled if it is still running
+	EventProcessTerminating EventKind = "process_terminating"
+	// EventProcessExited reports that a terminated process exited by itself
+	EventProcessExited EventKind = "process_exited"
+	// EventProcessKilled reports that a process was killed
+	EventProcessKilled EventKind = "process_killed"
+	// EventProcessSuspended reports that a process was frozen
+	EventProcessSuspended EventKind = "process_suspended"
+	// EventProcessResumed reports that a process frozen by sleego was resumed
+	EventProcessResumed EventKind = "process_resumed"
+	// EventShutdownWarning warns that the system will shut down at Scheduled
+	EventShutdownWarning EventKind = "shutdown_warning"
+	// EventShutdown reports that the system is being shut down
+	EventShutdown EventKind = "shutdown"
+	// EventShutdownFailed reports that the shutdown failed, with the reason in Error
+	EventShutdownFailed EventKind = "shutdown_failed"
+	// EventOverrideGranted reports that the rule of App is overridden until Scheduled
+	EventOverrideGranted EventKind = "override_granted"
+	// EventShutdownPostponed reports that the pending shutdown was moved to Scheduled
+	EventShutdownPostponed EventKind = "shutdown_postponed"
+	// EventOverrideDenied reports an override or postponement refused for a
+	// wrong PIN or the daily cap, with the reason in Error
+	EventOverrideDenied EventKind = "override_denied"
+)
+
+// Event is something a policy did or is about to do
+type Event struct {
+	Kind EventKind `json:"kind"`
+	Time time.Time `json:"time"`
+
+	App     string `json:"app,omitempty"`     // App is the name of the rule, an app or a category
+	Process string `json:"process,omitempty"` // Process is the name of the process acted on
+	Pid     int    `json:"pid,omitempty"`
+
+	Scheduled        time.Time `json:"scheduled,omitzero"`          // Scheduled is when a warned or pending action happens
+	MinutesRemaining int       `json:"minutes_remaining,omitempty"` // MinutesRemaining is the time left until Scheduled, rounded up
+	Action           string    `json:"action,omitempty"`            // Action is the power action of a shutdown, such as "suspend"
+
+	DryRun bool   `json:"dry_run,omitempty"` // DryRun is set when the action was only reported
+	Error  string `json:"error,omitempty"`   // Error is why the action failed, empty when it succeeded
+
+	Message string `json:"message"` // Message describes the event for people
+}
+
+// Default number of events buffered for a subscriber
+const defaultEventBuffer = 64
+
+// EventBus delivers events to its subscribers without ever blocking the
+// publisher. A subscriber that falls behind misses the events that don't fit
+// in its buffer. A nil *EventBus discards every event.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewEventBus creates an EventBus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel receiving every event published from now on,
+// buffering up to buffer of them (a default when not positive), and a
+// function that ends the subscription and closes the channel. The channel
+// of a nil bus is already closed.
+func (b *EventBus) Subscribe(buffer int) (<-chan Event, func()) {
+	if b == nil {
+		ch := make(chan Event)
+		close(ch)
+		return ch, func() {}
+	}
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, ch)
+			close(ch)
+		})
+	}
+}
+
+// Publish sends the event to every subscriber with room for it
+func (b *EventBus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// eventLog keeps the latest events, oldest first
+type eventLog struct {
+	mu     sync.Mutex
+	size   int
+	events []Event
+}
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{size: size}
+}
+
+func (l *eventLog) add(event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) == l.size {
+		copy(l.events, l.events[1:])
+		l.events = l.events[:l.size-1]
+	}
+	l.events = append(l.events, event)
+}
+
+// recent returns up to limit of the latest events, all kept when limit is not positive
+func (l *eventLog) recent(limit int) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start := 0
+	if limit > 0 && limit < len(l.events) {
+		start = len(l.events) - limit
+	}
+	return append([]Event{}, l.events[start:]...)
+}
@@ -0,0 +1,114 @@
+package sleego
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventBus_DeliversToEverySubscriber(t *testing.T) {
+	bus := NewEventBus()
+	first, _ := bus.Subscribe(1)
+	second, _ := bus.Subscribe(1)
+
+	bus.Publish(Event{Kind: EventProcessKilled, Pid: 42})
+	for _, ch := range []<-chan Event{first, second} {
+		select {
+		case event := <-ch:
+			if event.Kind != EventProcessKilled || event.Pid != 42 {
+				t.Errorf("Unexpected event: %+v", event)
+			}
+		default:
+			t.Error("Expected every subscriber to receive the event")
+		}
+	}
+}
+
+func TestEventBus_DropsEventsForFullSubscribers(t *testing.T) {
+	bus := NewEventBus()
+	ch, _ := bus.Subscribe(1)
+
+	bus.Publish(Event{Pid: 1})
+	bus.Publish(Event{Pid: 2})
+
+	if event := <-ch; event.Pid != 1 {
+		t.Errorf("Expected the first event, got %+v", event)
+	}
+	select {
+	case event := <-ch:
+		t.Errorf("Expected the event that didn't fit to be dropped, got %+v", event)
+	default:
+	}
+}
+
+func TestEventBus_Unsubscribe(t *testing.T) {
+	bus := NewEventBus()
+	ch, unsubscribe := bus.Subscribe(1)
+	unsubscribe()
+	unsubscribe()
+
+	bus.Publish(Event{Pid: 1})
+	if _, ok := <-ch; ok {
+		t.Error("Expected the channel to be closed without events")
+	}
+}
+
+func TestEventBus_NilDiscardsEvents(t *testing.T) {
+	var bus *EventBus
+	bus.Publish(Event{Pid: 1})
+
+	ch, unsubscribe := bus.Subscribe(1)
+	if _, ok := <-ch; ok {
+		t.Error("Expected the channel of a nil bus to be closed")
+	}
+	unsubscribe()
+}
+
+func TestEvent_JSON(t *testing.T) {
+	event := Event{Kind: EventProcessKilled, Time: time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC), App: "games", Process: "steam", Pid: 42, Message: "Killing process: steam, PID: 42"}
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{`"kind":"process_killed"`, `"app":"games"`, `"pid":42`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %s in %s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"scheduled", "dry_run", "error"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("Expected %s to be omitted from %s", unwanted, got)
+		}
+	}
+}
+
+func TestEventLog_KeepsLatestEvents(t *testing.T) {
+	log := newEventLog(3)
+	for pid := 1; pid <= 5; pid++ {
+		log.add(Event{Pid: pid})
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []int
+	}{
+		{name: "all", limit: 0, want: []int{3, 4, 5}},
+		{name: "limited", limit: 2, want: []int{4, 5}},
+		{name: "over the size", limit: 10, want: []int{3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, event := range log.recent(tt.limit) {
+				got = append(got, event.Pid)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recent(%d) = %v, want %v", tt.limit, got, tt.want)
+			}
+		})
+	}
+}
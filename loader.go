@@ -15,6 +15,37 @@ type FileConfig struct {
 	Apps       []AppConfig         `json:"apps"`
 	Shutdown   string              `json:"shutdown"`
 	Categories map[string][]string `json:"categories"`
+
+	// PowerAction is what happens at the shutdown time: "poweroff", the
+	// default, "reboot", "suspend", "hibernate", "lock" or "logout"
+	PowerAction string `json:"power_action,omitempty"`
+
+	// QuotaReset is the time of day (HH:MM) at which daily quotas start over, midnight by default
+	QuotaReset string `json:"quota_reset,omitempty"`
+
+	// PollInterval is how often the running processes are checked, as a duration such as "2s"
+	PollInterval string `json:"poll_interval,omitempty"`
+
+	// ShutdownWarnings are the minutes before the shutdown at which a warning is sent
+	ShutdownWarnings []int `json:"shutdown_warnings,omitempty"`
+
+	// KillWarnings are the minutes before a running app is stopped at which a warning is sent
+	KillWarnings []int `json:"kill_warnings,omitempty"`
+
+	// Notifiers are the sinks, such as desktop notifications, that events are delivered to
+	Notifiers []NotifierConfig `json:"notifiers,omitempty"`
+
+	// Protected adds processes to the built-in list of processes that are never stopped
+	Protected ProtectedConfig `json:"protected,omitzero"`
+
+	// Overrides protects and limits the overrides granted through the control API
+	Overrides OverrideConfig `json:"overrides,omitzero"`
+
+	// Snooze lets the shutdown be delayed a few times without a PIN
+	Snooze SnoozeConfig `json:"snooze,omitzero"`
+
+	// Curfew shuts the machine down again when it is used during a blocked period
+	Curfew CurfewConfig `json:"curfew,omitzero"`
 }
 
 // AppConfig is the struct that will be used to store the configuration of each app
@@ -22,6 +53,44 @@ type AppConfig struct {
 	Name        string `json:"name"`
 	AllowedFrom string `json:"allowed_from"` // AllowedFrom is the initial hour that the app is allowed to be used
 	AllowedTo   string `json:"allowed_to"`   // AllowedTo is the final hour that the app is allowed to be used
+
+	// Windows are additional intervals OR-ed with AllowedFrom/AllowedTo
+	Windows []TimeWindow `json:"windows,omitempty"`
+
+	// Schedule maps weekday lists such as "mon-fri" or "sat,sun" to the windows
+	// used on those days. Days not listed fall back to AllowedFrom/AllowedTo and Windows.
+	Schedule map[string]TimeWindows `json:"schedule,omitempty"`
+
+	// DailyQuota is how long the app may run per day, e.g. "2h" or "90m". For
+	// a category the quota is shared by all of its processes.
+	DailyQuota string `json:"daily_quota,omitempty"`
+
+	// GracePeriod, e.g. "10s", makes the policy ask the process to exit and
+	// only kill it if it is still running once the period is over. Without it
+	// processes are killed immediately.
+	GracePeriod string `json:"grace_period,omitempty"`
+
+	// Match narrows the rule to processes whose executable path, command line
+	// or owner also match, e.g. "java" processes whose command line mentions minecraft
+	Match *ProcessMatch `json:"match,omitempty"`
+
+	// Action is what happens to a blocked process: "kill", the default, or
+	// "suspend", which freezes it until its rule allows it again
+	Action string `json:"action,omitempty"`
+
+	// KillTree also stops every descendant of a blocked process, such as
+	// helpers and crash handlers it spawned
+	KillTree bool `json:"kill_tree,omitempty"`
+	// KillParent also stops the process that started a blocked process, such as a game launcher
+	KillParent bool `json:"kill_parent,omitempty"`
+}
+
+// ProcessMatch holds patterns that a process has to match in addition to its
+// name. Empty fields are ignored; they use the same syntax as app names.
+type ProcessMatch struct {
+	Exe     string `json:"exe,omitempty"`
+	Cmdline string `json:"cmdline,omitempty"`
+	User    string `json:"user,omitempty"`
 }
 
 type Loader struct {
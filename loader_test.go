@@ -3,6 +3,7 @@ package sleego
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -201,6 +202,36 @@ func TestLoader_Save_Success(t *testing.T) {
 	}
 }
 
+func TestLoader_Save_OmitsUnsetSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := FileConfig{
+		Shutdown: "21:00",
+		Snooze:   SnoozeConfig{Count: 2, Minutes: 10},
+	}
+
+	loader := &Loader{}
+	if err := loader.Save(path, config); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read saved config: %v", err)
+	}
+	var saved map[string]json.RawMessage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("Failed to unmarshal saved data: %v", err)
+	}
+
+	for _, key := range []string{"protected", "overrides", "curfew"} {
+		if _, ok := saved[key]; ok {
+			t.Errorf("Expected %q to be omitted, got %s", key, saved[key])
+		}
+	}
+	if _, ok := saved["snooze"]; !ok {
+		t.Errorf("Expected \"snooze\" to be saved, got %s", data)
+	}
+}
+
 func TestLoader_Save_InvalidPath(t *testing.T) {
 	appsConfig := []AppConfig{
 		{
@@ -0,0 +1,249 @@
+package sleego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Notifier delivers events to people, e.g. as desktop notifications.
+// Notify should give up once ctx is done and bound the time it takes itself.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Built-in notifier types, used in the type field of NotifierConfig
+const (
+	NotifierDesktop  = "desktop"
+	NotifierWall     = "wall"
+	NotifierTerminal = "terminal"
+	NotifierFile     = "file"
+	NotifierExec     = "exec"
+	NotifierWebhook  = "webhook"
+)
+
+// NotifierConfig selects a built-in notifier in config.json
+type NotifierConfig struct {
+	Type string `json:"type"`
+
+	// Path is the file events are appended to, for the file notifier
+	Path string `json:"path,omitempty"`
+
+	// Command is the program and arguments run for each event, for the exec notifier
+	Command []string `json:"command,omitempty"`
+
+	// URL is the endpoint events are posted to, for the webhook notifier
+	URL string `json:"url,omitempty"`
+	// Headers are added to each webhook request
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is a text/template over the Event rendering the webhook request
+	// body, the event as JSON when empty. Its json function quotes values.
+	Body string `json:"body,omitempty"`
+	// Retries is how often a failed webhook request is retried
+	Retries int `json:"retries,omitempty"`
+	// Timeout is how long each webhook request may take, like "5s"
+	Timeout string `json:"timeout,omitempty"`
+
+	// Events limits the notifier to these kinds of events, all when empty
+	Events []EventKind `json:"events,omitempty"`
+}
+
+// Longest a command run by a notifier may take
+const notifyTimeout = 10 * time.Second
+
+// Every kind of event, to validate notifier filters
+var eventKinds = map[EventKind]bool{
+	EventKillWarning:        true,
+	EventProcessTerminating: true,
+	EventProcessExited:      true,
+	EventProcessKilled:      true,
+	EventProcessSuspended:   true,
+	EventProcessResumed:     true,
+	EventShutdownWarning:    true,
+	EventShutdown:           true,
+	EventShutdownFailed:     true,
+	EventOverrideGranted:    true,
+	EventShutdownPostponed:  true,
+	EventOverrideDenied:     true,
+}
+
+// commandRunner runs a program to completion, writing stdin to it
+type commandRunner func(ctx context.Context, name string, args []string, env []string, stdin []byte) error
+
+func runCommand(ctx context.Context, name string, args []string, env []string, stdin []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// NewNotifier creates the built-in notifier selected by cfg
+func NewNotifier(cfg NotifierConfig) (Notifier, error) {
+	var notifier Notifier
+	switch cfg.Type {
+	case NotifierDesktop:
+		notifier = &DesktopNotifier{run: runCommand}
+	case NotifierWall:
+		notifier = &WallNotifier{run: runCommand}
+	case NotifierTerminal:
+		notifier = &TerminalNotifier{out: os.Stderr}
+	case NotifierFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("the %s notifier needs a path", NotifierFile)
+		}
+		notifier = &FileNotifier{path: cfg.Path}
+	case NotifierExec:
+		if len(cfg.Command) == 0 || cfg.Command[0] == "" {
+			return nil, fmt.Errorf("the %s notifier needs a command", NotifierExec)
+		}
+		notifier = &ExecNotifier{command: cfg.Command, run: runCommand}
+	case NotifierWebhook:
+		webhook, err := newWebhookNotifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		notifier = webhook
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+
+	if len(cfg.Events) == 0 {
+		return notifier, nil
+	}
+	kinds := make(map[EventKind]bool, len(cfg.Events))
+	for _, kind := range cfg.Events {
+		if !eventKinds[kind] {
+			return nil, fmt.Errorf("unknown event kind %q", kind)
+		}
+		kinds[kind] = true
+	}
+	return &filteredNotifier{notifier: notifier, kinds: kinds}, nil
+}
+
+// NewNotifiers creates the built-in notifiers selected in the configuration
+func NewNotifiers(configs []NotifierConfig) ([]Notifier, error) {
+	notifiers := make([]Notifier, 0, len(configs))
+	for i, cfg := range configs {
+		notifier, err := NewNotifier(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("notifiers[%d]: %w", i, err)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return notifiers, nil
+}
+
+// filteredNotifier only passes on the kinds of events it was configured for
+type filteredNotifier struct {
+	notifier Notifier
+	kinds    map[EventKind]bool
+}
+
+func (f *filteredNotifier) Notify(ctx context.Context, event Event) error {
+	if !f.kinds[event.Kind] {
+		return nil
+	}
+	return f.notifier.Notify(ctx, event)
+}
+
+// DesktopNotifier shows freedesktop notifications through notify-send. It
+// has to run in the session of the user who should see them.
+type DesktopNotifier struct {
+	run commandRunner
+}
+
+func (d *DesktopNotifier) Notify(ctx context.Context, event Event) error {
+	urgency := "normal"
+	if event.Kind == EventKillWarning || event.Kind == EventShutdownWarning {
+		urgency = "critical"
+	}
+	return d.run(ctx, "notify-send", []string{"--app-name=sleego", "--urgency=" + urgency, "Sleego", event.Message}, nil, nil)
+}
+
+// WallNotifier broadcasts events to every terminal with wall
+type WallNotifier struct {
+	run commandRunner
+}
+
+func (w *WallNotifier) Notify(ctx context.Context, event Event) error {
+	return w.run(ctx, "wall", nil, nil, []byte("Sleego: "+event.Message+"\n"))
+}
+
+// TerminalNotifier rings the bell of the terminal sleego runs in and prints the event
+type TerminalNotifier struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (t *TerminalNotifier) Notify(_ context.Context, event Event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := fmt.Fprintf(t.out, "\aSleego: %s\n", event.Message)
+	return err
+}
+
+// FileNotifier appends each event to a file as a line of JSON
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (f *FileNotifier) Notify(_ context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ExecNotifier runs a command for each event. The event is written to its
+// standard input as JSON and its main fields are set in the environment.
+type ExecNotifier struct {
+	command []string
+	run     commandRunner
+}
+
+func (e *ExecNotifier) Notify(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	env := []string{
+		"SLEEGO_EVENT_KIND=" + string(event.Kind),
+		"SLEEGO_EVENT_MESSAGE=" + event.Message,
+		"SLEEGO_EVENT_APP=" + event.App,
+		"SLEEGO_EVENT_PROCESS=" + event.Process,
+		"SLEEGO_EVENT_PID=" + strconv.Itoa(event.Pid),
+	}
+	return e.run(ctx, e.command[0], e.command[1:], env, data)
+}
+
+var _ Notifier = &DesktopNotifier{}
+var _ Notifier = &WallNotifier{}
+var _ Notifier = &TerminalNotifier{}
+var _ Notifier = &FileNotifier{}
+var _ Notifier = &ExecNotifier{}
@@ -0,0 +1,201 @@
+package sleego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordedCommand is a command run by a notifier under test
+type recordedCommand struct {
+	name  string
+	args  []string
+	env   []string
+	stdin []byte
+}
+
+type commandRecorder struct {
+	commands []recordedCommand
+}
+
+func (r *commandRecorder) run(_ context.Context, name string, args []string, env []string, stdin []byte) error {
+	r.commands = append(r.commands, recordedCommand{name: name, args: args, env: env, stdin: stdin})
+	return nil
+}
+
+// recordingNotifier keeps the events it is notified of
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []Event
+	seen   chan struct{}
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, event Event) error {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+	if r.seen != nil {
+		r.seen <- struct{}{}
+	}
+	return nil
+}
+
+var testEvent = Event{Kind: EventProcessKilled, App: "games", Process: "steam", Pid: 42, Message: "Killing process: steam, PID: 42"}
+
+func TestNewNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     NotifierConfig
+		wantErr bool
+	}{
+		{name: "desktop", cfg: NotifierConfig{Type: "desktop"}},
+		{name: "wall", cfg: NotifierConfig{Type: "wall"}},
+		{name: "terminal", cfg: NotifierConfig{Type: "terminal"}},
+		{name: "file", cfg: NotifierConfig{Type: "file", Path: "events.jsonl"}},
+		{name: "exec", cfg: NotifierConfig{Type: "exec", Command: []string{"notify-parent", "--urgent"}}},
+		{name: "filtered", cfg: NotifierConfig{Type: "desktop", Events: []EventKind{EventKillWarning, EventShutdownWarning}}},
+		{name: "unknown type", cfg: NotifierConfig{Type: "pager"}, wantErr: true},
+		{name: "file without path", cfg: NotifierConfig{Type: "file"}, wantErr: true},
+		{name: "exec without command", cfg: NotifierConfig{Type: "exec"}, wantErr: true},
+		{name: "unknown event kind", cfg: NotifierConfig{Type: "desktop", Events: []EventKind{"exploded"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier, err := NewNotifier(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewNotifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && notifier == nil {
+				t.Error("NewNotifier() returned no notifier")
+			}
+		})
+	}
+}
+
+func TestFilteredNotifier(t *testing.T) {
+	recorder := &recordingNotifier{}
+	notifier := &filteredNotifier{notifier: recorder, kinds: map[EventKind]bool{EventKillWarning: true}}
+
+	notifier.Notify(context.Background(), testEvent)
+	notifier.Notify(context.Background(), Event{Kind: EventKillWarning})
+	if len(recorder.events) != 1 || recorder.events[0].Kind != EventKillWarning {
+		t.Errorf("Expected only the kill warning, got %+v", recorder.events)
+	}
+}
+
+func TestDesktopNotifier(t *testing.T) {
+	recorder := &commandRecorder{}
+	notifier := &DesktopNotifier{run: recorder.run}
+
+	notifier.Notify(context.Background(), testEvent)
+	notifier.Notify(context.Background(), Event{Kind: EventShutdownWarning, Message: "Shutting down in 5 minutes"})
+
+	want := []recordedCommand{
+		{name: "notify-send", args: []string{"--app-name=sleego", "--urgency=normal", "Sleego", "Killing process: steam, PID: 42"}},
+		{name: "notify-send", args: []string{"--app-name=sleego", "--urgency=critical", "Sleego", "Shutting down in 5 minutes"}},
+	}
+	if !reflect.DeepEqual(recorder.commands, want) {
+		t.Errorf("Commands = %+v, want %+v", recorder.commands, want)
+	}
+}
+
+func TestWallNotifier(t *testing.T) {
+	recorder := &commandRecorder{}
+	notifier := &WallNotifier{run: recorder.run}
+
+	notifier.Notify(context.Background(), testEvent)
+	if len(recorder.commands) != 1 || recorder.commands[0].name != "wall" {
+		t.Fatalf("Expected wall to run, got %+v", recorder.commands)
+	}
+	if got := string(recorder.commands[0].stdin); got != "Sleego: Killing process: steam, PID: 42\n" {
+		t.Errorf("Unexpected wall message %q", got)
+	}
+}
+
+func TestTerminalNotifier(t *testing.T) {
+	var out bytes.Buffer
+	notifier := &TerminalNotifier{out: &out}
+
+	if err := notifier.Notify(context.Background(), testEvent); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if got := out.String(); got != "\aSleego: Killing process: steam, PID: 42\n" {
+		t.Errorf("Unexpected output %q", got)
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	notifier := &FileNotifier{path: path}
+
+	for _, event := range []Event{testEvent, {Kind: EventShutdownWarning, MinutesRemaining: 5}} {
+		if err := notifier.Notify(context.Background(), event); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", data)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if event.Kind != testEvent.Kind || event.Pid != testEvent.Pid || event.App != testEvent.App {
+		t.Errorf("Unexpected event %+v", event)
+	}
+}
+
+func TestExecNotifier(t *testing.T) {
+	recorder := &commandRecorder{}
+	notifier := &ExecNotifier{command: []string{"notify-parent", "--urgent"}, run: recorder.run}
+
+	notifier.Notify(context.Background(), testEvent)
+	if len(recorder.commands) != 1 {
+		t.Fatalf("Expected one command, got %+v", recorder.commands)
+	}
+	cmd := recorder.commands[0]
+	if cmd.name != "notify-parent" || !reflect.DeepEqual(cmd.args, []string{"--urgent"}) {
+		t.Errorf("Unexpected command %s %v", cmd.name, cmd.args)
+	}
+	for _, want := range []string{"SLEEGO_EVENT_KIND=process_killed", "SLEEGO_EVENT_APP=games", "SLEEGO_EVENT_PID=42"} {
+		if !strings.Contains(strings.Join(cmd.env, "\n"), want) {
+			t.Errorf("Expected %s in the environment %v", want, cmd.env)
+		}
+	}
+	var event Event
+	if err := json.Unmarshal(cmd.stdin, &event); err != nil || event.Message != testEvent.Message {
+		t.Errorf("Expected the event as JSON on stdin, got %q (%v)", cmd.stdin, err)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	err := runCommand(context.Background(), "sh", []string{"-c", `cat > "$OUT"; echo "$SLEEGO_EVENT_KIND" >> "$OUT"`}, []string{"OUT=" + out, "SLEEGO_EVENT_KIND=shutdown"}, []byte("event\n"))
+	if err != nil {
+		t.Fatalf("runCommand() error = %v", err)
+	}
+	data, _ := os.ReadFile(out)
+	if got := string(data); got != "event\nshutdown\n" {
+		t.Errorf("Unexpected output %q", got)
+	}
+
+	if err := runCommand(context.Background(), "sh", []string{"-c", "echo broken >&2; exit 3"}, nil, nil); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected the failure and its output, got %v", err)
+	}
+}
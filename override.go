@@ -0,0 +1,170 @@
+package sleego
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Longest override or shutdown postponement that can be granted at once
+const maxOverride = 12 * time.Hour
+
+// Consecutive wrong PINs after which no PIN is accepted for pinLockout
+const (
+	maxPINFailures = 5
+	pinLockout     = 5 * time.Minute
+)
+
+// Reasons an override is refused before it is granted
+var (
+	ErrWrongPIN      = errors.New("wrong PIN")
+	ErrPINLocked     = errors.New("too many wrong PINs, try again later")
+	ErrOverrideLimit = errors.New("no overrides left for today")
+)
+
+// OverrideConfig protects and limits the overrides and shutdown
+// postponements granted while Sleego runs
+type OverrideConfig struct {
+	// PinHash is the hash of the PIN required to grant one, as printed by
+	// sleego -hash-pin. None is required when it is empty.
+	PinHash string `json:"pin_hash,omitempty"`
+
+	// MaxPerDay caps how many are granted per day, unlimited when zero
+	MaxPerDay int `json:"max_per_day,omitempty"`
+}
+
+// overrideGuard checks the PIN and the daily cap before an override is granted
+type overrideGuard struct {
+	mu          sync.Mutex
+	pin         *pinHash // nil when no PIN is required
+	maxPerDay   int
+	periodStart func(now time.Time) time.Time // start of the day an override counts for
+	period      time.Time
+	granted     int // overrides granted in period
+	failures    int // consecutive wrong PINs
+	lockedUntil time.Time
+}
+
+func newOverrideGuard(cfg OverrideConfig, periodStart func(now time.Time) time.Time) (*overrideGuard, error) {
+	g := &overrideGuard{periodStart: periodStart}
+	if err := g.configure(cfg); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// configure applies a new configuration, keeping the count of overrides granted today
+func (g *overrideGuard) configure(cfg OverrideConfig) error {
+	var pin *pinHash
+	if cfg.PinHash != "" {
+		hash, err := parsePINHash(cfg.PinHash)
+		if err != nil {
+			return err
+		}
+		pin = &hash
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pin, g.maxPerDay = pin, cfg.MaxPerDay
+	return nil
+}
+
+// grant calls grant if the PIN is right and the daily cap isn't reached,
+// counting it against the cap when it succeeds
+func (g *overrideGuard) grant(now time.Time, pin string, grant func() error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.Before(g.lockedUntil) {
+		return ErrPINLocked
+	}
+	if g.pin != nil && !g.pin.verify(pin) {
+		g.failures++
+		if g.failures >= maxPINFailures {
+			g.failures, g.lockedUntil = 0, now.Add(pinLockout)
+		}
+		return ErrWrongPIN
+	}
+	g.failures = 0
+
+	if start := g.periodStart(now); !start.Equal(g.period) {
+		g.period, g.granted = start, 0
+	}
+	if g.maxPerDay > 0 && g.granted >= g.maxPerDay {
+		return ErrOverrideLimit
+	}
+	if err := grant(); err != nil {
+		return err
+	}
+	g.granted++
+	return nil
+}
+
+// Override lets the processes of a rule run until a given time, whatever
+// the rule says. Quota usage is still counted while it lasts.
+type Override struct {
+	App   string    `json:"app"`
+	Until time.Time `json:"until"`
+}
+
+// Grant overrides the rule named app for the given duration from now,
+// replacing any override it already has
+func (p *ProcessPolicyImpl) Grant(app string, d time.Duration) (Override, error) {
+	if _, ok := findApp(p.Apps(), app); !ok {
+		return Override{}, fmt.Errorf("unknown app %q", app)
+	}
+	if d <= 0 || d > maxOverride {
+		return Override{}, fmt.Errorf("invalid override duration %v, must be positive and at most %v", d, maxOverride)
+	}
+
+	override := Override{App: app, Until: p.now().Add(d)}
+	p.overridesMu.Lock()
+	p.overrides[app] = override.Until
+	p.overridesMu.Unlock()
+	p.publish(Event{Kind: EventOverrideGranted, App: app, Scheduled: override.Until, Message: fmt.Sprintf("Override granted for %s until %s", app, override.Until.Format("15:04"))})
+	return override, nil
+}
+
+// Revoke ends the override of the rule named app, reporting whether it had one
+func (p *ProcessPolicyImpl) Revoke(app string) bool {
+	p.overridesMu.Lock()
+	_, ok := p.overrides[app]
+	delete(p.overrides, app)
+	p.overridesMu.Unlock()
+	if ok {
+		p.logger.Info("Override revoked for " + app)
+	}
+	return ok
+}
+
+// Overrides returns the overrides still in effect, sorted by app
+func (p *ProcessPolicyImpl) Overrides() []Override {
+	now := p.now()
+	p.overridesMu.Lock()
+	defer p.overridesMu.Unlock()
+	overrides := make([]Override, 0, len(p.overrides))
+	for app, until := range p.overrides {
+		if now.Before(until) {
+			overrides = append(overrides, Override{App: app, Until: until})
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].App < overrides[j].App })
+	return overrides
+}
+
+// overrideEnd returns when the override of the rule named app ends, if it
+// has one in effect at now. Expired overrides are forgotten.
+func (p *ProcessPolicyImpl) overrideEnd(app string, now time.Time) (time.Time, bool) {
+	p.overridesMu.Lock()
+	defer p.overridesMu.Unlock()
+	until, ok := p.overrides[app]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !now.Before(until) {
+		delete(p.overrides, app)
+		return time.Time{}, false
+	}
+	return until, true
+}
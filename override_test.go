@@ -0,0 +1,207 @@
+package sleego
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGrant_AllowsBlockedApp(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 501}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{game}}
+	appsConfig := []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}}
+
+	now := time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time { return now }, nil)
+	policy.SetApps(appsConfig)
+
+	override, err := policy.Grant("game", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+	if want := now.Add(30 * time.Minute); !override.Until.Equal(want) {
+		t.Errorf("Override until %v, want %v", override.Until, want)
+	}
+
+	policy.enforceProcessPolicy(appsConfig)
+	if killed, _ := game.state(); killed {
+		t.Fatal("Process must not be killed while its override lasts")
+	}
+
+	now = now.Add(31 * time.Minute)
+	policy.enforceProcessPolicy(appsConfig)
+	if killed, _ := game.state(); !killed {
+		t.Error("Expected the process to be killed once its override ended")
+	}
+	if overrides := policy.Overrides(); len(overrides) != 0 {
+		t.Errorf("Expected the expired override to be gone, got %v", overrides)
+	}
+}
+
+func TestGrant_Validation(t *testing.T) {
+	policy := NewProcessPolicyImpl(&MockProcessorMonitor{}, nil, nil, nil)
+	policy.SetApps([]AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}})
+
+	tests := []struct {
+		name    string
+		app     string
+		d       time.Duration
+		wantErr bool
+	}{
+		{name: "valid", app: "game", d: time.Hour},
+		{name: "unknown app", app: "browser", d: time.Hour, wantErr: true},
+		{name: "zero duration", app: "game", d: 0, wantErr: true},
+		{name: "too long", app: "game", d: maxOverride + time.Minute, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := policy.Grant(tt.app, tt.d)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	now := time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	policy := NewProcessPolicyImpl(&MockProcessorMonitor{}, nil, func() time.Time { return now }, nil)
+	policy.SetApps([]AppConfig{{Name: "game"}, {Name: "browser"}})
+	policy.Grant("game", time.Hour)
+	policy.Grant("browser", 2*time.Hour)
+
+	if !policy.Revoke("game") {
+		t.Error("Expected Revoke() to report the override")
+	}
+	if policy.Revoke("game") {
+		t.Error("Expected a second Revoke() to find nothing")
+	}
+	want := []Override{{App: "browser", Until: now.Add(2 * time.Hour)}}
+	if got := policy.Overrides(); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("Overrides() = %v, want %v", got, want)
+	}
+}
+
+func TestBlockedAt_WithOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		override time.Duration
+		want     time.Time
+	}{
+		{
+			name:     "override after the window",
+			now:      time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC),
+			override: 30 * time.Minute,
+			want:     time.Date(2023, 10, 10, 18, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "override ending within the window",
+			now:      time.Date(2023, 10, 10, 16, 0, 0, 0, time.UTC),
+			override: 30 * time.Minute,
+			want:     time.Date(2023, 10, 10, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "override extending the window",
+			now:      time.Date(2023, 10, 10, 16, 50, 0, 0, time.UTC),
+			override: 30 * time.Minute,
+			want:     time.Date(2023, 10, 10, 17, 20, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appConfig := AppConfig{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}
+			policy := NewProcessPolicyImpl(&MockProcessorMonitor{}, nil, func() time.Time { return tt.now }, nil)
+			policy.SetApps([]AppConfig{appConfig})
+			if _, err := policy.Grant("game", tt.override); err != nil {
+				t.Fatalf("Grant() error = %v", err)
+			}
+
+			got, ok := policy.blockedAt(appConfig, tt.now)
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("blockedAt() = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrant_PublishesEvent(t *testing.T) {
+	now := time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	bus, ch := newTestBus(1)
+	policy := NewProcessPolicyImpl(&MockProcessorMonitor{}, nil, func() time.Time { return now }, bus)
+	policy.SetApps([]AppConfig{{Name: "browsers"}})
+
+	policy.Grant("browsers", 30*time.Minute)
+	event := <-ch
+	if event.Kind != EventOverrideGranted || event.App != "browsers" || !event.Scheduled.Equal(now.Add(30*time.Minute)) {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if event.Message != "Override granted for browsers until 18:30" {
+		t.Errorf("Unexpected message: %s", event.Message)
+	}
+}
+
+func newTestOverrideGuard(t *testing.T, cfg OverrideConfig) *overrideGuard {
+	t.Helper()
+	guard, err := newOverrideGuard(cfg, newUsageTracker(0).periodStart)
+	if err != nil {
+		t.Fatalf("newOverrideGuard() error = %v", err)
+	}
+	return guard
+}
+
+func TestOverrideGuard_ChecksPIN(t *testing.T) {
+	guard := newTestOverrideGuard(t, OverrideConfig{PinHash: testPINHash(t, "1234")})
+	now := time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	granted := 0
+	grant := func() error { granted++; return nil }
+
+	if err := guard.grant(now, "0000", grant); !errors.Is(err, ErrWrongPIN) {
+		t.Errorf("Expected ErrWrongPIN, got %v", err)
+	}
+	if err := guard.grant(now, "1234", grant); err != nil {
+		t.Errorf("Expected the right PIN to grant, got %v", err)
+	}
+	if granted != 1 {
+		t.Errorf("Expected 1 grant, got %d", granted)
+	}
+}
+
+func TestOverrideGuard_LocksOutAfterWrongPINs(t *testing.T) {
+	guard := newTestOverrideGuard(t, OverrideConfig{PinHash: testPINHash(t, "1234")})
+	now := time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	grant := func() error { return nil }
+
+	for i := 0; i < maxPINFailures; i++ {
+		if err := guard.grant(now, "0000", grant); !errors.Is(err, ErrWrongPIN) {
+			t.Fatalf("Attempt %d: expected ErrWrongPIN, got %v", i, err)
+		}
+	}
+	if err := guard.grant(now, "1234", grant); !errors.Is(err, ErrPINLocked) {
+		t.Errorf("Expected even the right PIN to be locked out, got %v", err)
+	}
+	if err := guard.grant(now.Add(pinLockout), "1234", grant); err != nil {
+		t.Errorf("Expected the right PIN to grant after the lockout, got %v", err)
+	}
+}
+
+func TestOverrideGuard_CapsOverridesPerDay(t *testing.T) {
+	guard := newTestOverrideGuard(t, OverrideConfig{MaxPerDay: 2})
+	now := time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	grant := func() error { return nil }
+
+	if err := guard.grant(now, "", func() error { return errors.New("unknown app") }); err == nil {
+		t.Fatal("Expected the failed grant to be returned")
+	}
+	for i := 0; i < 2; i++ {
+		if err := guard.grant(now, "", grant); err != nil {
+			t.Fatalf("Grant %d: unexpected error %v", i, err)
+		}
+	}
+	if err := guard.grant(now, "", grant); !errors.Is(err, ErrOverrideLimit) {
+		t.Errorf("Expected ErrOverrideLimit, got %v", err)
+	}
+	if err := guard.grant(now.Add(6*time.Hour), "", grant); err != nil {
+		t.Errorf("Expected the cap to start over the next day, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package sleego
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Prefix that marks a pattern as a regular expression
+const regexPatternPrefix = "re:"
+
+// namePattern matches process names and other process attributes. A pattern
+// is matched literally unless it is prefixed with "re:", which makes the rest
+// a regular expression, or contains one of *, ? or [, which makes it a glob.
+// Both have to match the whole value. In globs * also matches path
+// separators, so "/opt/games/*" covers everything below /opt/games.
+type namePattern struct {
+	raw string
+	re  *regexp.Regexp // nil for literal patterns
+}
+
+func compileNamePattern(raw string) (namePattern, error) {
+	if expr, ok := strings.CutPrefix(raw, regexPatternPrefix); ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return namePattern{}, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+		}
+		return namePattern{raw: raw, re: re}, nil
+	}
+	if isGlob(raw) {
+		re, err := compileGlob(raw)
+		if err != nil {
+			return namePattern{}, fmt.Errorf("invalid glob %q: %w", raw, err)
+		}
+		return namePattern{raw: raw, re: re}, nil
+	}
+	return namePattern{raw: raw}, nil
+}
+
+func isGlob(raw string) bool {
+	return strings.ContainsAny(raw, "*?[")
+}
+
+// compileGlob translates a glob into an anchored regular expression. It
+// supports *, ?, character classes such as [a-z] or [!0-9] and \ to escape
+// the next character.
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '\\':
+			if i+1 == len(glob) {
+				return nil, fmt.Errorf("trailing escape")
+			}
+			i++
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if class == "" || class == "!" {
+				return nil, fmt.Errorf("empty character class")
+			}
+			if negated, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + negated
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// isLiteral reports whether the pattern only matches the exact value
+func (n namePattern) isLiteral() bool {
+	return n.re == nil
+}
+
+func (n namePattern) match(value string) bool {
+	if n.re != nil {
+		return n.re.MatchString(value)
+	}
+	return n.raw == value
+}
+
+// patterns returns every pattern of the app: its name and the match fields that are set
+func (a AppConfig) patterns() []string {
+	patterns := []string{a.Name}
+	if a.Match != nil {
+		for _, raw := range []string{a.Match.Exe, a.Match.Cmdline, a.Match.User} {
+			if raw != "" {
+				patterns = append(patterns, raw)
+			}
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,52 @@
+package sleego
+
+import "testing"
+
+func TestNamePattern_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "chrome", name: "chrome", want: true},
+		{pattern: "chrome", name: "chrome_crashpad_handler", want: false},
+		{pattern: "chrome*", name: "chrome_crashpad_handler", want: true},
+		{pattern: "game-v?.?", name: "game-v2.3", want: true},
+		{pattern: "game-v?.?", name: "game-v2.10", want: false},
+		{pattern: "[Ss]team*", name: "Steam.exe", want: true},
+		{pattern: `re:^game-v[0-9.]+$`, name: "game-v2.10", want: true},
+		{pattern: `re:^game-v[0-9.]+$`, name: "game-launcher", want: false},
+		{pattern: "re:craft", name: "minecraft-launcher", want: false},
+		{pattern: "re:.*craft.*", name: "minecraft-launcher", want: true},
+		{pattern: "re:steam|lutris", name: "steamwebhelper", want: false},
+		{pattern: "re:steam|lutris", name: "lutris", want: true},
+		{pattern: "/home/kid/Games/*", name: "/home/kid/Games/factorio/bin/factorio", want: true},
+		{pattern: "/home/kid/Games/*", name: "/usr/bin/factorio", want: false},
+		{pattern: "*minecraft*", name: "/usr/bin/java -jar /opt/minecraft/launcher.jar", want: true},
+		{pattern: "game[!0-9]", name: "gamex", want: true},
+		{pattern: "game[!0-9]", name: "game1", want: false},
+		{pattern: `what\?`, name: "what?", want: true},
+		{pattern: `what\?`, name: "whats", want: false},
+		{pattern: "game.exe", name: "gamexexe", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.name, func(t *testing.T) {
+			pattern, err := compileNamePattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("compileNamePattern(%q) error = %v", tt.pattern, err)
+			}
+			if got := pattern.match(tt.name); got != tt.want {
+				t.Errorf("match(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileNamePattern_Invalid(t *testing.T) {
+	for _, raw := range []string{"game[", "game[]", "re:game(", "re:*", `game*\`} {
+		if _, err := compileNamePattern(raw); err == nil {
+			t.Errorf("compileNamePattern(%q) expected an error", raw)
+		}
+	}
+}
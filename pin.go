@@ -0,0 +1,80 @@
+package sleego
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Prefix of PIN hashes, which look like pbkdf2-sha256$<iterations>$<salt>$<key>
+// with salt and key in unpadded base64
+const pinHashScheme = "pbkdf2-sha256"
+
+// PBKDF2 iterations of new PIN hashes
+const pinIterations = 600_000
+
+const (
+	pinSaltSize = 16
+	pinKeySize  = 32
+)
+
+// HashPIN hashes a PIN or password for the pin_hash field of the overrides configuration
+func HashPIN(pin string) (string, error) {
+	return hashPIN(pin, pinIterations)
+}
+
+func hashPIN(pin string, iterations int) (string, error) {
+	if pin == "" {
+		return "", errors.New("the PIN must not be empty")
+	}
+	salt := make([]byte, pinSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, pin, salt, iterations, pinKeySize)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("%s$%d$%s$%s", pinHashScheme, iterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// pinHash is a parsed PIN hash
+type pinHash struct {
+	iterations int
+	salt       []byte
+	key        []byte
+}
+
+func parsePINHash(hash string) (pinHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != pinHashScheme {
+		return pinHash{}, fmt.Errorf("unsupported PIN hash, expected %s$<iterations>$<salt>$<key>", pinHashScheme)
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return pinHash{}, fmt.Errorf("invalid PIN hash iterations %q", parts[1])
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil || len(salt) == 0 {
+		return pinHash{}, errors.New("invalid PIN hash salt")
+	}
+	key, err := enc.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return pinHash{}, errors.New("invalid PIN hash key")
+	}
+	return pinHash{iterations: iterations, salt: salt, key: key}, nil
+}
+
+// verify reports whether pin hashes to the same key
+func (h pinHash) verify(pin string) bool {
+	key, err := pbkdf2.Key(sha256.New, pin, h.salt, h.iterations, len(h.key))
+	return err == nil && subtle.ConstantTimeCompare(key, h.key) == 1
+}
@@ -0,0 +1,67 @@
+package sleego
+
+import (
+	"strings"
+	"testing"
+)
+
+// Few iterations keep the tests fast; verification reads them from the hash
+const testPINIterations = 1000
+
+func testPINHash(t *testing.T, pin string) string {
+	t.Helper()
+	hash, err := hashPIN(pin, testPINIterations)
+	if err != nil {
+		t.Fatalf("hashPIN() error = %v", err)
+	}
+	return hash
+}
+
+func TestHashPIN_Verifies(t *testing.T) {
+	hash := testPINHash(t, "1234")
+	parsed, err := parsePINHash(hash)
+	if err != nil {
+		t.Fatalf("parsePINHash() error = %v", err)
+	}
+	if !parsed.verify("1234") {
+		t.Error("Expected the PIN to verify")
+	}
+	if parsed.verify("4321") || parsed.verify("") {
+		t.Error("Expected a wrong PIN to be rejected")
+	}
+	if other := testPINHash(t, "1234"); other == hash {
+		t.Error("Expected every hash to have its own salt")
+	}
+}
+
+func TestHashPIN_RejectsEmptyPIN(t *testing.T) {
+	if _, err := HashPIN(""); err == nil {
+		t.Error("Expected an empty PIN to be rejected")
+	}
+}
+
+func TestParsePINHash(t *testing.T) {
+	valid := testPINHash(t, "1234")
+	parts := strings.Split(valid, "$")
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr bool
+	}{
+		{name: "valid", hash: valid},
+		{name: "plain PIN", hash: "1234", wantErr: true},
+		{name: "other scheme", hash: "bcrypt$" + strings.Join(parts[1:], "$"), wantErr: true},
+		{name: "invalid iterations", hash: strings.Join([]string{parts[0], "many", parts[2], parts[3]}, "$"), wantErr: true},
+		{name: "invalid salt", hash: strings.Join([]string{parts[0], parts[1], "not base64!", parts[3]}, "$"), wantErr: true},
+		{name: "missing key", hash: strings.Join([]string{parts[0], parts[1], parts[2], ""}, "$"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePINHash(tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package sleego
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// Power actions taken at the shutdown time, used in the power_action field of FileConfig
+const (
+	PowerOff       = "poweroff"
+	PowerReboot    = "reboot"
+	PowerSuspend   = "suspend"
+	PowerHibernate = "hibernate"
+	PowerLock      = "lock"
+	PowerLogout    = "logout"
+)
+
+// PowerActionSetter is implemented by shutdown policies that can take
+// another action than powering off at the shutdown time
+type PowerActionSetter interface {
+	// SetPowerAction selects the action, powering off when it is empty
+	SetPowerAction(action string) error
+}
+
+// powerAction describes a power action
+type powerAction struct {
+	name       string
+	verb       string // verb is the action in messages, such as "Shutting down"
+	infinitive string // infinitive is the action in dry run messages, such as "shut down"
+
+	// keepsRunning is set when the machine keeps running or resumes after
+	// the action, so it is taken again at the next shutdown time
+	keepsRunning bool
+
+	linux []string // linux is the command taking the action on Linux
+}
+
+var powerActions = map[string]powerAction{
+	PowerOff:       {verb: "Shutting down", infinitive: "shut down", linux: []string{"shutdown", "-h", "now"}},
+	PowerReboot:    {verb: "Rebooting", infinitive: "reboot", linux: []string{"systemctl", "reboot"}},
+	PowerSuspend:   {verb: "Suspending", infinitive: "suspend", keepsRunning: true, linux: []string{"systemctl", "suspend"}},
+	PowerHibernate: {verb: "Hibernating", infinitive: "hibernate", keepsRunning: true, linux: []string{"systemctl", "hibernate"}},
+	PowerLock:      {verb: "Locking the screen", infinitive: "lock the screen", keepsRunning: true, linux: []string{"loginctl", "lock-sessions"}},
+	PowerLogout:    {verb: "Logging out", infinitive: "log out", keepsRunning: true, linux: []string{"loginctl", "terminate-seat", "seat0"}},
+}
+
+// lookupPowerAction returns the power action named action, powering off when it is empty
+func lookupPowerAction(action string) (powerAction, error) {
+	if action == "" {
+		action = PowerOff
+	}
+	a, ok := powerActions[action]
+	if !ok {
+		return powerAction{}, fmt.Errorf("unknown power action %q", action)
+	}
+	a.name = action
+	return a, nil
+}
+
+// powerCommand returns the command taking action on goos. Logging out ends
+// the session Sleego runs in, if any, and otherwise every session on the
+// local seat.
+func powerCommand(goos, action string, getenv func(string) string) ([]string, error) {
+	a, err := lookupPowerAction(action)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case goos == "linux" && action == PowerLogout && getenv("XDG_SESSION_ID") != "":
+		return []string{"loginctl", "terminate-session", getenv("XDG_SESSION_ID")}, nil
+	case goos == "linux":
+		return a.linux, nil
+	case action != "" && action != PowerOff:
+		return nil, fmt.Errorf("the %s power action is not supported on %s", action, goos)
+	case goos == "windows":
+		return []string{"shutdown", "/s", "/f", "/t", "0"}, nil
+	case goos == "darwin":
+		return []string{"sudo", "shutdown", "-h", "now"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported operating system %s", goos)
+	}
+}
+
+// Longest a command taking a power action may take. Suspending and
+// hibernating only return once the machine is back, which may be a while.
+const powerCommandTimeout = 2 * time.Minute
+
+// PowerCommandRunner runs a command taking a power action to completion
+type PowerCommandRunner func(ctx context.Context, name string, args []string) error
+
+func runPowerCommand(ctx context.Context, name string, args []string) error {
+	ctx, cancel := context.WithTimeout(ctx, powerCommandTimeout)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// WithPowerCommandRunner replaces how the commands taking the power actions
+// are run, e.g. to go through a privileged helper or to record them in tests
+func WithPowerCommandRunner(run PowerCommandRunner) ShutdownPolicyOption {
+	return func(s *ShutdownPolicyImpl) {
+		s.runner = run
+	}
+}
+
+// SetPowerAction selects the action taken at the shutdown time, checking
+// that it is supported on this system unless it is the default
+func (s *ShutdownPolicyImpl) SetPowerAction(action string) error {
+	if action != "" {
+		if _, err := powerCommand(runtime.GOOS, action, os.Getenv); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.action = action
+	return nil
+}
+
+// powerAction returns the action taken at the shutdown time
+func (s *ShutdownPolicyImpl) powerAction() powerAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, _ := lookupPowerAction(s.action)
+	return a
+}
+
+// runPowerAction takes the power action through the command runner
+func (s *ShutdownPolicyImpl) runPowerAction() error {
+	s.mu.Lock()
+	action := s.action
+	s.mu.Unlock()
+	command, err := powerCommand(runtime.GOOS, action, os.Getenv)
+	if err != nil {
+		return err
+	}
+	return s.runner(context.Background(), command[0], command[1:])
+}
+
+var _ PowerActionSetter = &ShutdownPolicyImpl{}
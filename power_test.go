@@ -0,0 +1,164 @@
+package sleego
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/joaogabriel01/sleego/clock/fake"
+)
+
+func TestPowerCommand(t *testing.T) {
+	noSession := func(string) string { return "" }
+	tests := []struct {
+		name    string
+		goos    string
+		action  string
+		getenv  func(string) string
+		want    []string
+		wantErr bool
+	}{
+		{name: "default", goos: "linux", want: []string{"shutdown", "-h", "now"}},
+		{name: "reboot", goos: "linux", action: PowerReboot, want: []string{"systemctl", "reboot"}},
+		{name: "suspend", goos: "linux", action: PowerSuspend, want: []string{"systemctl", "suspend"}},
+		{name: "hibernate", goos: "linux", action: PowerHibernate, want: []string{"systemctl", "hibernate"}},
+		{name: "lock", goos: "linux", action: PowerLock, want: []string{"loginctl", "lock-sessions"}},
+		{name: "logout outside a session", goos: "linux", action: PowerLogout, want: []string{"loginctl", "terminate-seat", "seat0"}},
+		{
+			name:   "logout in a session",
+			goos:   "linux",
+			action: PowerLogout,
+			getenv: func(key string) string {
+				if key == "XDG_SESSION_ID" {
+					return "3"
+				}
+				return ""
+			},
+			want: []string{"loginctl", "terminate-session", "3"},
+		},
+		{name: "poweroff on windows", goos: "windows", action: PowerOff, want: []string{"shutdown", "/s", "/f", "/t", "0"}},
+		{name: "suspend on windows", goos: "windows", action: PowerSuspend, wantErr: true},
+		{name: "unknown action", goos: "linux", action: "sleep", wantErr: true},
+		{name: "unsupported system", goos: "plan9", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := tt.getenv
+			if getenv == nil {
+				getenv = noSession
+			}
+			got, err := powerCommand(tt.goos, tt.action, getenv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("powerCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// runPower records a command taking a power action
+func (r *commandRecorder) runPower(_ context.Context, name string, args []string) error {
+	r.commands = append(r.commands, recordedCommand{name: name, args: args})
+	return nil
+}
+
+func TestShutdownPolicyImpl_RunsPowerAction(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("power actions other than poweroff are only supported on Linux")
+	}
+	recorder := &commandRecorder{}
+	policy := NewShutdownPolicyImpl(nil, nil, WithPowerCommandRunner(recorder.runPower)).(*ShutdownPolicyImpl)
+	if err := policy.SetPowerAction(PowerHibernate); err != nil {
+		t.Fatalf("SetPowerAction() error = %v", err)
+	}
+
+	if err := policy.shutdown(); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+	want := []recordedCommand{{name: "systemctl", args: []string{"hibernate"}}}
+	if !reflect.DeepEqual(recorder.commands, want) {
+		t.Errorf("Expected %+v, got %+v", want, recorder.commands)
+	}
+}
+
+func TestShutdownPolicyImpl_SuspendSchedulesNextDay(t *testing.T) {
+	clock := fake.NewClock(shutdownTestNow)
+	events, ch := newTestBus(10)
+	policy, shutdowns := newPostponableShutdownPolicy(clock, events, nil)
+	policy.action = PowerSuspend
+	endTime := shutdownTestNow.Add(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := applyAsync(ctx, policy, endTime)
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	if at := <-shutdowns; !at.Equal(endTime) {
+		t.Errorf("Suspended at %v, want %v", at, endTime)
+	}
+	if event := <-ch; event.Kind != EventShutdown || event.Action != PowerSuspend || event.Message != "Suspending now" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	// The machine resumes and the next suspend is scheduled
+	clock.BlockUntil(1)
+	if got, _ := policy.Scheduled(); !got.Equal(endTime.Add(24 * time.Hour)) {
+		t.Errorf("Expected the next suspend the following day, got %v", got)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Apply to run until canceled, got %v", err)
+	}
+}
+
+func TestShutdownPolicyImpl_ShutdownMissedWhileAsleep(t *testing.T) {
+	clock := fake.NewClock(shutdownTestNow)
+	events, ch := newTestBus(10)
+	policy, shutdowns := newPostponableShutdownPolicy(clock, events, nil)
+	endTime := shutdownTestNow.Add(time.Hour)
+
+	done := applyAsync(context.Background(), policy, endTime)
+	clock.BlockUntil(1)
+	// Timers stop while the machine sleeps, so the wall clock jumps past the shutdown
+	clock.Advance(3 * time.Hour)
+	woke := clock.Now()
+	event := <-ch
+	if event.Kind != EventShutdownWarning || !event.Scheduled.Equal(woke.Add(missedShutdownGrace)) || event.Message != "Missed the 13:00 shutdown while asleep, shutting down in 2 minutes" {
+		t.Errorf("Unexpected warning %+v", event)
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(missedShutdownGrace)
+	if err := <-done; err != nil {
+		t.Errorf("Apply returned error: %v", err)
+	}
+	if at := <-shutdowns; !at.Equal(woke.Add(missedShutdownGrace)) {
+		t.Errorf("Shut down at %v, want shortly after waking up", at)
+	}
+}
+
+func TestShutdownPolicyImpl_CurfewShutdownDroppedAfterCurfew(t *testing.T) {
+	clock := fake.NewClock(curfewTestNow)
+	policy, shutdowns := newCurfewShutdownPolicy(t, clock, nil, nil)
+	endTime := time.Date(0, 1, 1, 23, 59, 0, 0, time.UTC)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	applyAsync(ctx, policy, endTime)
+	clock.BlockUntil(1)
+	// Asleep until after the curfew
+	clock.Advance(8 * time.Hour)
+	clock.BlockUntil(1)
+	select {
+	case at := <-shutdowns:
+		t.Fatalf("Shut down at %v after the curfew", at)
+	default:
+	}
+	if got, _ := policy.Scheduled(); !got.Equal(time.Date(2023, 10, 11, 23, 59, 0, 0, time.UTC)) {
+		t.Errorf("Expected the daily shutdown to be pending, got %v", got)
+	}
+}
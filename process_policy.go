@@ -3,8 +3,12 @@ package sleego
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/joaogabriel01/sleego/clock"
 	"github.com/joaogabriel01/sleego/internal/logger"
 )
 
@@ -13,40 +17,374 @@ type ProcessPolicy interface {
 	Apply(ctx context.Context, appsConfig []AppConfig) error
 }
 
-// Time to sleep between checks
-const sleepTime = 5 * time.Second
+// Time between checks unless configured otherwise
+const defaultPollInterval = 5 * time.Second
+
+// Bounds of a configured poll interval
+const (
+	minPollInterval = time.Second
+	maxPollInterval = time.Minute
+)
+
+// Actions taken on a blocked process
+const (
+	ActionKill    = "kill"
+	ActionSuspend = "suspend"
+)
+
+// Longest gap between two checks that is still counted as usage, in poll
+// intervals. Larger gaps mean the machine was suspended or the policy was stalled.
+const maxUsageSteps = 2
+
+// How often the usage is written to the state store while the policy runs
+const stateSaveInterval = time.Minute
+
+// Longest grace period a process gets to exit before it is killed
+const maxGracePeriod = 5 * time.Minute
+
+// How often a terminated process is checked while its grace period runs
+const terminateCheckInterval = 500 * time.Millisecond
 
 // ProcessPolicyImpl is the implementation of the ProcessPolicy interface
 type ProcessPolicyImpl struct {
 	monitor          ProcessorMonitor
 	categoryOperator CategoryOperator
 	now              func() time.Time
-	alertCh          chan string
+	clock            clock.Clock
+	events           *EventBus
 	logger           logger.Logger
+	usage            *usageTracker
+	lastCheck        time.Time
+	store            StateStore
+	lastSave         time.Time
+	appsMu           sync.RWMutex
+	apps             []AppConfig
+	patternsMu       sync.Mutex
+	patterns         map[string]namePattern // compiled app names and match patterns
+	terminatingMu    sync.Mutex
+	terminating      map[int]bool // PIDs waiting for their grace period to end
+	warningsMu       sync.RWMutex
+	killWarnings     []int // minutes before a process is stopped at which to warn
+	warned           map[killWarning]bool
+	exhaustedMu      sync.Mutex
+	exhausted        map[string]time.Time // start of the quota period each rule was logged as exhausted in
+	dryRun           bool
+	reportedMu       sync.Mutex
+	reported         map[int]bool // PIDs a dry run already reported stopping
+	protectedMu      sync.RWMutex
+	protected        protectedSet
+	suspendedMu      sync.Mutex
+	suspended        map[int]suspendedMatch // processes suspended by this policy, by PID
+	savedSuspended   []SuspendedProcess     // processes a previous run left suspended, resumed by Apply
+	pollMu           sync.RWMutex
+	pollInterval     time.Duration
+	ticks            <-chan time.Time // injected tick source, nil to use a ticker
+	overridesMu      sync.Mutex
+	overrides        map[string]time.Time // end of the override granted to each rule
+	ctxMu            sync.Mutex
+	ctx              context.Context // context of the running Apply, nil before it starts
 }
 
-// NewProcessPolicyImpl creates a new ProcessPolicyImpl
-func NewProcessPolicyImpl(monitor ProcessorMonitor, categoryOperator CategoryOperator, now func() time.Time, alert chan string) *ProcessPolicyImpl {
-	if now == nil {
-		now = time.Now
+// killWarning identifies a warning already sent for a running process
+type killWarning struct {
+	pid  int
+	lead int
+}
+
+// ProcessPolicyOption configures optional behavior of a ProcessPolicyImpl
+type ProcessPolicyOption func(*ProcessPolicyImpl)
+
+// WithQuotaReset sets the time of day at which daily quotas start over.
+// Only the hour and minute of t are used; the default is midnight.
+func WithQuotaReset(t time.Time) ProcessPolicyOption {
+	return func(p *ProcessPolicyImpl) {
+		p.usage = newUsageTracker(quotaResetOffset(t))
+	}
+}
+
+// WithStateStore persists the quota usage so it survives restarts. The saved
+// state is loaded when the policy is created and written back periodically.
+func WithStateStore(store StateStore) ProcessPolicyOption {
+	return func(p *ProcessPolicyImpl) {
+		p.store = store
 	}
+}
+
+// WithKillWarnings makes the policy warn, the given numbers of minutes ahead,
+// before a running process leaves its allowed windows or uses up its quota
+func WithKillWarnings(minutes []int) ProcessPolicyOption {
+	return func(p *ProcessPolicyImpl) {
+		p.killWarnings = append([]int(nil), minutes...)
+	}
+}
+
+// WithDryRun makes the policy only report the processes it would stop,
+// without ever terminating or killing them
+func WithDryRun() ProcessPolicyOption {
+	return func(p *ProcessPolicyImpl) {
+		p.dryRun = true
+	}
+}
+
+// WithProtected adds processes that must never be stopped to the built-in list
+func WithProtected(cfg ProtectedConfig) ProcessPolicyOption {
+	return func(p *ProcessPolicyImpl) {
+		p.protected = newProtectedSet(cfg)
+	}
+}
+
+// WithPollInterval sets how often the running processes are checked. Zero
+// keeps the default of 5 seconds.
+func WithPollInterval(d time.Duration) ProcessPolicyOption {
+	return func(p *ProcessPolicyImpl) {
+		if d > 0 {
+			p.pollInterval = d
+		}
+	}
+}
+
+// WithTicks makes Apply check the processes whenever ticks delivers a value
+// instead of on its own ticker, so callers such as tests can step the policy
+func WithTicks(ticks <-chan time.Time) ProcessPolicyOption {
+	return func(p *ProcessPolicyImpl) {
+		p.ticks = ticks
+	}
+}
+
+// WithClock replaces the real clock, e.g. with a fake one in tests. It also
+// tells the time unless NewProcessPolicyImpl is given a now function.
+func WithClock(c clock.Clock) ProcessPolicyOption {
+	return func(p *ProcessPolicyImpl) {
+		p.clock = c
+	}
+}
+
+// NewProcessPolicyImpl creates a new ProcessPolicyImpl
+// Events are published on events, which may be nil.
+func NewProcessPolicyImpl(monitor ProcessorMonitor, categoryOperator CategoryOperator, now func() time.Time, events *EventBus, opts ...ProcessPolicyOption) *ProcessPolicyImpl {
 	logger, err := logger.Get()
 	if err != nil {
 		panic(fmt.Sprintf("failed to get logger: %v", err))
 	}
-	return &ProcessPolicyImpl{monitor: monitor, categoryOperator: categoryOperator, now: now, alertCh: alert, logger: logger}
+	p := &ProcessPolicyImpl{
+		monitor:          monitor,
+		categoryOperator: categoryOperator,
+		now:              now,
+		clock:            clock.Real(),
+		events:           events,
+		logger:           logger,
+		usage:            newUsageTracker(0),
+		terminating:      make(map[int]bool),
+		warned:           make(map[killWarning]bool),
+		exhausted:        make(map[string]time.Time),
+		reported:         make(map[int]bool),
+		patterns:         make(map[string]namePattern),
+		protected:        newProtectedSet(ProtectedConfig{}),
+		suspended:        make(map[int]suspendedMatch),
+		pollInterval:     defaultPollInterval,
+		overrides:        make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.now == nil {
+		p.now = p.clock.Now
+	}
+	p.loadState()
+	return p
 }
 
 // Apply will check the running processes and kill the ones that are not allowed to run
 func (p *ProcessPolicyImpl) Apply(ctx context.Context, appsConfig []AppConfig) error {
+	p.ctxMu.Lock()
+	p.ctx = ctx
+	p.ctxMu.Unlock()
+	p.SetApps(appsConfig)
+	p.resumeSaved()
+	execs := p.watchExec(ctx)
+	interval := p.PollInterval()
+	ticks := p.ticks
+	var ticker clock.Ticker
+	if ticks == nil {
+		ticker = p.clock.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C()
+	}
+
+	p.enforceProcessPolicy(p.Apps())
 	for {
-		if ctx.Err() != nil {
+		select {
+		case <-ctx.Done():
 			p.logger.Debug("Context cancelled, stopping process policy")
+			p.resumeAll()
+			p.saveState()
 			return nil
+		case process, ok := <-execs:
+			if !ok {
+				// Polling alone still enforces every rule
+				execs = nil
+				continue
+			}
+			p.enforceNewProcess(p.Apps(), process)
+		case <-ticks:
+			p.enforceProcessPolicy(p.Apps())
+			if d := p.PollInterval(); ticker != nil && d != interval {
+				interval = d
+				ticker.Reset(d)
+			}
+		}
+	}
+}
+
+// watchExec subscribes to process starts when the monitor supports it. The
+// returned channel is nil otherwise, which leaves Apply polling only.
+func (p *ProcessPolicyImpl) watchExec(ctx context.Context) <-chan Process {
+	watcher, ok := p.monitor.(ProcessWatcher)
+	if !ok {
+		return nil
+	}
+	execs, err := watcher.WatchExec(ctx)
+	if err != nil {
+		p.logger.Info(fmt.Sprintf("Process events unavailable, falling back to polling: %v", err))
+		return nil
+	}
+	p.logger.Debug("Watching process events")
+	return execs
+}
+
+// SetApps replaces the rules enforced by a running Apply, starting with the
+// next check. App name patterns are compiled here once.
+func (p *ProcessPolicyImpl) SetApps(appsConfig []AppConfig) {
+	apps := make([]AppConfig, len(appsConfig))
+	copy(apps, appsConfig)
+
+	patterns := make(map[string]namePattern, len(apps))
+	for _, app := range apps {
+		for _, raw := range app.patterns() {
+			pattern, err := compileNamePattern(raw)
+			if err != nil {
+				p.logger.Error(fmt.Sprintf("Error compiling pattern %s of %s: %v", raw, app.Name, err))
+				continue
+			}
+			patterns[raw] = pattern
+		}
+	}
+	p.patternsMu.Lock()
+	p.patterns = patterns
+	p.patternsMu.Unlock()
+
+	p.appsMu.Lock()
+	defer p.appsMu.Unlock()
+	p.apps = apps
+}
+
+// SetPollInterval changes how often a running Apply checks the processes,
+// starting after its next check. Zero restores the default.
+func (p *ProcessPolicyImpl) SetPollInterval(d time.Duration) {
+	if d <= 0 {
+		d = defaultPollInterval
+	}
+	p.pollMu.Lock()
+	defer p.pollMu.Unlock()
+	p.pollInterval = d
+}
+
+// PollInterval returns how often the processes are checked
+func (p *ProcessPolicyImpl) PollInterval() time.Duration {
+	p.pollMu.RLock()
+	defer p.pollMu.RUnlock()
+	return p.pollInterval
+}
+
+// SetQuotaReset sets the time of day at which daily quotas start over, like
+// WithQuotaReset. The time already used today still counts.
+func (p *ProcessPolicyImpl) SetQuotaReset(t time.Time) {
+	p.usage.setResetAt(quotaResetOffset(t), p.now())
+}
+
+// quotaResetOffset returns the offset from midnight of the hour and minute of t
+func quotaResetOffset(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// SetKillWarnings replaces the minutes ahead at which to warn before a
+// running process is stopped, like WithKillWarnings
+func (p *ProcessPolicyImpl) SetKillWarnings(minutes []int) {
+	minutes = append([]int(nil), minutes...)
+	p.warningsMu.Lock()
+	defer p.warningsMu.Unlock()
+	p.killWarnings = minutes
+}
+
+// SetProtected replaces the configured protected processes. The built-in
+// ones, sleego itself and its parent are always protected.
+func (p *ProcessPolicyImpl) SetProtected(cfg ProtectedConfig) {
+	set := newProtectedSet(cfg)
+	p.protectedMu.Lock()
+	defer p.protectedMu.Unlock()
+	p.protected = set
+}
+
+func (p *ProcessPolicyImpl) isProtected(info ProcessInfo) bool {
+	p.protectedMu.RLock()
+	defer p.protectedMu.RUnlock()
+	return p.protected.contains(info)
+}
+
+// Apps returns the rules currently enforced
+func (p *ProcessPolicyImpl) Apps() []AppConfig {
+	p.appsMu.RLock()
+	defer p.appsMu.RUnlock()
+	apps := make([]AppConfig, len(p.apps))
+	copy(apps, p.apps)
+	return apps
+}
+
+// MatchedProcess is a running process covered by a rule
+type MatchedProcess struct {
+	App       string    `json:"app"`
+	Process   string    `json:"process"`
+	Pid       int       `json:"pid"`
+	Allowed   bool      `json:"allowed"`             // Allowed is whether the rule lets it run now
+	Suspended bool      `json:"suspended,omitempty"` // Suspended is set when the policy froze it
+	BlockedAt time.Time `json:"blocked_at,omitzero"` // BlockedAt is when an allowed process will be stopped
+}
+
+// Matches returns the running processes covered by the current rules, one
+// entry per rule a process matches. Protected processes are left out.
+func (p *ProcessPolicyImpl) Matches() ([]MatchedProcess, error) {
+	processes, err := p.monitor.GetRunningProcesses()
+	if err != nil {
+		return nil, fmt.Errorf("error getting running processes: %w", err)
+	}
+
+	apps := p.Apps()
+	now := p.now()
+	matches := []MatchedProcess{}
+	for _, process := range processes {
+		info, err := process.GetInfo()
+		if err != nil || p.isProtected(info) {
+			continue
+		}
+		for _, appConfig := range apps {
+			if !p.matchesApp(process, &info, appConfig) {
+				continue
+			}
+			match := MatchedProcess{App: appConfig.Name, Process: info.Name, Pid: info.Pid, Allowed: p.isAllowed(appConfig, now), Suspended: p.isSuspended(info.Pid)}
+			if match.Allowed {
+				match.BlockedAt, _ = p.blockedAt(appConfig, now)
+			}
+			matches = append(matches, match)
 		}
-		p.enforceProcessPolicy(appsConfig)
-		time.Sleep(sleepTime)
 	}
+	return matches, nil
+}
+
+// processMatch is a running process together with a rule that applies to it
+type processMatch struct {
+	process   Process
+	info      ProcessInfo
+	appConfig AppConfig
 }
 
 func (p *ProcessPolicyImpl) enforceProcessPolicy(appsConfig []AppConfig) {
@@ -56,68 +394,735 @@ func (p *ProcessPolicyImpl) enforceProcessPolicy(appsConfig []AppConfig) {
 		return
 	}
 
+	now := p.now()
+	elapsed := now.Sub(p.lastCheck)
+	if p.lastCheck.IsZero() || elapsed < 0 || elapsed > maxUsageSteps*p.PollInterval() {
+		elapsed = 0
+	}
+	p.lastCheck = now
+
+	var matches []processMatch
+	running := make(map[string]AppConfig)
+	runningPids := make(map[int]bool)
 	for _, process := range processes {
 		info, err := process.GetInfo()
 		if err != nil {
 			p.logger.Error(fmt.Sprintf("Error getting process info: %v", err))
 			continue
 		}
+		runningPids[info.Pid] = true
+
+		if p.isProtected(info) {
+			continue
+		}
 
 		p.logger.Debug(fmt.Sprintf("Checking process: %s, PID: %d", info.Name, info.Pid))
 		for _, appConfig := range appsConfig {
-			if info.Name == appConfig.Name || (p.categoryOperator != nil && existElementInSlice(p.categoryOperator.GetCategoriesOf(info.Name), appConfig.Name)) {
-
-				// Check if the process is running outside the allowed hours
-				if !p.isAllowedToRun(appConfig) {
-					msg := fmt.Sprintf("Killing process: %s, PID: %d", info.Name, info.Pid)
-					if p.alertCh != nil {
-						p.alertCh <- msg
-					}
-					p.logger.Info(msg)
-					err := process.Kill()
-					if err != nil {
-						p.logger.Error(fmt.Sprintf("Error killing process: %v", err))
-						continue
-					}
+			if p.matchesApp(process, &info, appConfig) {
+				matches = append(matches, processMatch{process: process, info: info, appConfig: appConfig})
+				// Suspended processes don't use up the quota
+				if !p.isSuspended(info.Pid) {
+					running[appConfig.Name] = appConfig
 				}
 			}
 		}
 	}
+
+	// A rule consumes its quota once per check, no matter how many of its
+	// processes are running, so category members share a single budget
+	for name, appConfig := range running {
+		if appConfig.DailyQuota != "" {
+			used := p.usage.add(name, elapsed, now)
+			p.logger.Debug(fmt.Sprintf("Usage of %s: %v of %s", name, used, appConfig.DailyQuota))
+		}
+	}
+
+	if now.Sub(p.lastSave) >= stateSaveInterval {
+		p.saveState()
+	}
+
+	var allowed []processMatch
+	stopped := make(map[int]bool)
+	for _, match := range matches {
+		// Check if the process is running outside the allowed hours or over its quota
+		if !p.isAllowed(match.appConfig, now) {
+			p.stopWithRelatives(match, stopped)
+			continue
+		}
+		allowed = append(allowed, match)
+	}
+	p.resumeAllowed(appsConfig, runningPids, now)
+	p.forgetReported(runningPids)
+	p.warnBeforeStop(allowed, now)
 }
 
-func (p *ProcessPolicyImpl) isAllowedToRun(appConfig AppConfig) bool {
+// enforceNewProcess checks a single process that just started, so a blocked
+// one is stopped before it gets going. Quota usage, resuming and warnings are
+// left to the regular checks.
+func (p *ProcessPolicyImpl) enforceNewProcess(appsConfig []AppConfig, process Process) {
+	info, err := process.GetInfo()
+	if err != nil {
+		// Short lived processes often exit before they can be read
+		p.logger.Debug(fmt.Sprintf("Error getting process info: %v", err))
+		return
+	}
+	if p.isProtected(info) {
+		return
+	}
+
 	now := p.now()
-	allowedFrom, err := time.Parse("15:04", appConfig.AllowedFrom)
+	stopped := make(map[int]bool)
+	for _, appConfig := range appsConfig {
+		if p.matchesApp(process, &info, appConfig) && !p.isAllowed(appConfig, now) {
+			p.stopWithRelatives(processMatch{process: process, info: info, appConfig: appConfig}, stopped)
+		}
+	}
+}
+
+// stopWithRelatives stops a blocked process and, depending on the rule, its
+// parent and descendants. The whole tree is collected before anything is
+// stopped, as children of a stopped process are reparented and can no longer
+// be found. The parent goes first so a launcher can't respawn its children.
+// PIDs in stopped are skipped and the stopped ones are added to it.
+func (p *ProcessPolicyImpl) stopWithRelatives(match processMatch, stopped map[int]bool) {
+	targets := []processMatch{match}
+	if match.appConfig.KillTree {
+		targets = append(targets, p.descendants(match, map[int]bool{match.info.Pid: true})...)
+	}
+	if match.appConfig.KillParent {
+		if parent, ok := p.parent(match); ok {
+			targets = append([]processMatch{parent}, targets...)
+		}
+	}
+
+	for _, target := range targets {
+		if stopped[target.info.Pid] {
+			continue
+		}
+		if p.isProtected(target.info) {
+			p.logger.Info(fmt.Sprintf("Not stopping protected process %s, PID: %d related to %s", target.info.Name, target.info.Pid, match.info.Name))
+			continue
+		}
+		stopped[target.info.Pid] = true
+		p.stop(target)
+	}
+}
+
+// descendants returns every process below match, depth first. visited guards
+// against PIDs being reused while the tree is walked.
+func (p *ProcessPolicyImpl) descendants(match processMatch, visited map[int]bool) []processMatch {
+	children, err := match.process.Children()
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("Error getting children of %s, PID: %d: %v", match.info.Name, match.info.Pid, err))
+		return nil
+	}
+
+	var result []processMatch
+	for _, child := range children {
+		info, err := child.GetInfo()
+		if err != nil || visited[info.Pid] {
+			continue
+		}
+		visited[info.Pid] = true
+		childMatch := processMatch{process: child, info: info, appConfig: match.appConfig}
+		result = append(result, childMatch)
+		result = append(result, p.descendants(childMatch, visited)...)
+	}
+	return result
+}
+
+func (p *ProcessPolicyImpl) parent(match processMatch) (processMatch, bool) {
+	parent, err := match.process.Parent()
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("Error getting parent of %s, PID: %d: %v", match.info.Name, match.info.Pid, err))
+		return processMatch{}, false
+	}
+	info, err := parent.GetInfo()
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("Error getting parent info of %s, PID: %d: %v", match.info.Name, match.info.Pid, err))
+		return processMatch{}, false
+	}
+	return processMatch{process: parent, info: info, appConfig: match.appConfig}, true
+}
+
+// warnBeforeStop alerts about allowed processes that are about to be stopped.
+// Each lead time is announced once per PID, so the warning does not repeat on
+// every check.
+func (p *ProcessPolicyImpl) warnBeforeStop(matches []processMatch, now time.Time) {
+	p.warningsMu.RLock()
+	leads := p.killWarnings
+	p.warningsMu.RUnlock()
+	if len(leads) == 0 {
+		return
+	}
+
+	// A process matched by several rules is stopped by the first of them to block it
+	type pending struct {
+		match     processMatch
+		blockedAt time.Time
+	}
+	var order []int
+	earliest := make(map[int]*pending)
+	seen := make(map[int]bool)
+	for _, match := range matches {
+		pid := match.info.Pid
+		seen[pid] = true
+		blockedAt, ok := p.blockedAt(match.appConfig, now)
+		if !ok {
+			continue
+		}
+		if e, found := earliest[pid]; !found {
+			earliest[pid] = &pending{match: match, blockedAt: blockedAt}
+			order = append(order, pid)
+		} else if blockedAt.Before(e.blockedAt) {
+			e.match, e.blockedAt = match, blockedAt
+		}
+	}
+
+	for _, pid := range order {
+		match, blockedAt := earliest[pid].match, earliest[pid].blockedAt
+		remaining := blockedAt.Sub(now)
+
+		send := false
+		for _, lead := range leads {
+			key := killWarning{pid: pid, lead: lead}
+			if remaining > time.Duration(lead)*time.Minute || remaining <= 0 {
+				// Out of range again, e.g. a new window started, so warn next time
+				delete(p.warned, key)
+				continue
+			}
+			if !p.warned[key] {
+				p.warned[key] = true
+				send = true
+			}
+		}
+		if send {
+			minutes := int(math.Ceil(remaining.Minutes()))
+			event := processEvent(EventKillWarning, match, fmt.Sprintf("Process %s, PID: %d will be closed in %d minutes", match.info.Name, match.info.Pid, minutes))
+			event.Scheduled, event.MinutesRemaining = blockedAt, minutes
+			p.publish(event)
+		}
+	}
+
+	for key := range p.warned {
+		if !seen[key.pid] {
+			delete(p.warned, key)
+		}
+	}
+}
+
+// blockedAt returns when a process of an app that is allowed now will have to
+// stop if it keeps running, because its window ends, its quota runs out or
+// its override ends
+func (p *ProcessPolicyImpl) blockedAt(appConfig AppConfig, now time.Time) (time.Time, bool) {
+	at, found := p.ruleBlockedAt(appConfig, now)
+	until, overridden := p.overrideEnd(appConfig.Name, now)
+	if !overridden {
+		return at, found
+	}
+	// The rule may keep allowing the app after the override ends
+	if p.allowedByRule(appConfig, now) && (!found || at.After(until)) {
+		return at, found
+	}
+	return until, true
+}
+
+// ruleBlockedAt is blockedAt without overrides
+func (p *ProcessPolicyImpl) ruleBlockedAt(appConfig AppConfig, now time.Time) (time.Time, bool) {
+	var at time.Time
+	found := false
+	if appConfig.hasWindows() {
+		at, found = appConfig.windowEnd(now)
+	}
+	if appConfig.DailyQuota != "" {
+		quota, err := time.ParseDuration(appConfig.DailyQuota)
+		if err != nil {
+			return at, found
+		}
+		quotaEnd := now.Add(quota - p.usage.get(appConfig.Name, now))
+		nextReset := p.usage.periodStart(now).AddDate(0, 0, 1)
+		if quotaEnd.Before(nextReset) && (!found || quotaEnd.Before(at)) {
+			at, found = quotaEnd, true
+		}
+	}
+	return at, found
+}
+
+// matchesApp reports whether a process is covered by a rule: its name has to
+// match the app name pattern or one of the categories of the process, and its
+// executable, command line and owner the patterns of the rule, if any. Those
+// are read into info when the rule needs them and GetInfo left them out.
+func (p *ProcessPolicyImpl) matchesApp(process Process, info *ProcessInfo, appConfig AppConfig) bool {
+	nameMatches := p.matchesPattern(appConfig.Name, info.Name) ||
+		(p.categoryOperator != nil && existElementInSlice(p.categoryOperator.GetCategoriesOf(info.Name), appConfig.Name))
+	if !nameMatches {
+		return false
+	}
+	if m := appConfig.Match; m != nil {
+		loadDetails(process, info, m)
+		if (m.Exe != "" && !p.matchesPattern(m.Exe, info.Exe)) ||
+			(m.Cmdline != "" && !p.matchesPattern(m.Cmdline, info.Cmdline)) ||
+			(m.User != "" && !p.matchesPattern(m.User, info.Username)) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadDetails reads the details the patterns of m need, unless they are known
+func loadDetails(process Process, info *ProcessInfo, m *ProcessMatch) {
+	detailer, ok := process.(ProcessDetailer)
+	if !ok {
+		return
+	}
+	if m.Exe != "" && info.Exe == "" {
+		info.Exe, _ = detailer.Exe()
+	}
+	if m.Cmdline != "" && info.Cmdline == "" {
+		info.Cmdline, _ = detailer.Cmdline()
+	}
+	if m.User != "" && info.Username == "" {
+		info.Username, _ = detailer.Username()
+	}
+}
+
+// matchesPattern matches a value against a pattern. Values that could not be
+// read never match.
+func (p *ProcessPolicyImpl) matchesPattern(raw, value string) bool {
+	if value == "" {
+		return false
+	}
+	pattern, ok := p.compiledPattern(raw)
+	return ok && pattern.match(value)
+}
+
+// compiledPattern returns a compiled pattern, compiling and caching it if it
+// was not compiled by SetApps
+func (p *ProcessPolicyImpl) compiledPattern(raw string) (namePattern, bool) {
+	p.patternsMu.Lock()
+	defer p.patternsMu.Unlock()
+	if pattern, ok := p.patterns[raw]; ok {
+		return pattern, true
+	}
+	pattern, err := compileNamePattern(raw)
 	if err != nil {
-		p.logger.Error("Error parsing allowedFrom: " + err.Error())
+		p.logger.Error(fmt.Sprintf("Error compiling pattern %s: %v", raw, err))
+		return namePattern{}, false
+	}
+	p.patterns[raw] = pattern
+	return pattern, true
+}
+
+// stop ends a process that is not allowed to run, right away or, when the
+// rule has a grace period, by asking it to exit and killing it afterwards
+func (p *ProcessPolicyImpl) stop(match processMatch) {
+	if match.appConfig.Action == ActionSuspend {
+		p.suspend(match)
+		return
+	}
+
+	var grace time.Duration
+	if match.appConfig.GracePeriod != "" {
+		var err error
+		grace, err = time.ParseDuration(match.appConfig.GracePeriod)
+		if err != nil {
+			p.logger.Error(fmt.Sprintf("Error parsing grace period of %s: %v", match.appConfig.Name, err))
+		}
+	}
+	if p.dryRun {
+		if !p.firstReport(match.info.Pid) {
+			return
+		}
+		event := processEvent(EventProcessKilled, match, fmt.Sprintf("Dry run, would kill process: %s, PID: %d", match.info.Name, match.info.Pid))
+		event.DryRun = true
+		p.publish(event)
+		return
+	}
+	if grace <= 0 {
+		p.kill(match)
+		return
+	}
+
+	if !p.startTerminating(match.info.Pid) {
+		p.logger.Debug(fmt.Sprintf("Process %s, PID: %d is already being terminated", match.info.Name, match.info.Pid))
+		return
+	}
+	event := processEvent(EventProcessTerminating, match, fmt.Sprintf("Terminating process: %s, PID: %d, killing it in %v", match.info.Name, match.info.Pid, grace))
+	event.Scheduled = p.clock.Now().Add(grace)
+	event.MinutesRemaining = int(math.Ceil(grace.Minutes()))
+	if err := match.process.Terminate(); err != nil {
+		p.logger.Error(fmt.Sprintf("Error terminating process, killing it instead: %v", err))
+		event.Error = err.Error()
+		p.publish(event)
+		p.kill(match)
+		p.finishTerminating(match.info.Pid)
+		return
+	}
+	p.publish(event)
+	go p.escalate(p.applyCtx(), match, grace)
+}
+
+// applyCtx returns the context of the running Apply, or the background
+// context when the policy is driven without it
+func (p *ProcessPolicyImpl) applyCtx() context.Context {
+	p.ctxMu.Lock()
+	defer p.ctxMu.Unlock()
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// escalate waits for a terminated process to exit and kills it if it is still
+// running when the grace period is over, unless the process was allowed again
+// in the meantime. It gives up once ctx is done.
+func (p *ProcessPolicyImpl) escalate(ctx context.Context, match processMatch, grace time.Duration) {
+	defer p.finishTerminating(match.info.Pid)
+
+	deadline := p.clock.Now().Add(grace)
+	for {
+		running, err := match.process.IsRunning()
+		if err == nil && !running {
+			p.publish(processEvent(EventProcessExited, match, fmt.Sprintf("Process exited: %s, PID: %d", match.info.Name, match.info.Pid)))
+			return
+		}
+		remaining := deadline.Sub(p.clock.Now())
+		if remaining <= 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock.After(min(terminateCheckInterval, remaining)):
+		}
+	}
+
+	// An override or a reload may have allowed the process during the grace period
+	appConfig, ok := findApp(p.Apps(), match.appConfig.Name)
+	if !ok || !p.matchesApp(match.process, &match.info, appConfig) || p.isAllowed(appConfig, p.now()) {
+		p.logger.Info(fmt.Sprintf("Process %s, PID: %d is allowed again, not killing it", match.info.Name, match.info.Pid))
+		return
+	}
+	p.logger.Info(fmt.Sprintf("Grace period of %v is over for %s, PID: %d", grace, match.info.Name, match.info.Pid))
+	p.kill(processMatch{process: match.process, info: match.info, appConfig: appConfig})
+}
+
+// suspend freezes a blocked process and remembers it, so that only processes
+// suspended by the policy are resumed later
+func (p *ProcessPolicyImpl) suspend(match processMatch) {
+	if p.isSuspended(match.info.Pid) {
+		return
+	}
+	event := processEvent(EventProcessSuspended, match, fmt.Sprintf("Suspending process: %s, PID: %d", match.info.Name, match.info.Pid))
+	if p.dryRun {
+		if !p.firstReport(match.info.Pid) {
+			return
+		}
+		event.Message = fmt.Sprintf("Dry run, would suspend process: %s, PID: %d", match.info.Name, match.info.Pid)
+		event.DryRun = true
+		p.publish(event)
+		return
+	}
+	if err := match.process.Suspend(); err != nil {
+		p.logger.Error(fmt.Sprintf("Error suspending process: %v", err))
+		event.Error = err.Error()
+		p.publish(event)
+		return
+	}
+	p.publish(event)
+	suspended := suspendedMatch{processMatch: match}
+	if reporter, ok := match.process.(ProcessStartReporter); ok {
+		suspended.started, _ = reporter.StartTime()
+	}
+	p.suspendedMu.Lock()
+	p.suspended[match.info.Pid] = suspended
+	p.suspendedMu.Unlock()
+	// Saved right away, so the process is resumed even if sleego is killed
+	p.saveState()
+}
+
+// suspendedMatch is a process suspended by the policy
+type suspendedMatch struct {
+	processMatch
+	started time.Time // zero when the start time could not be read
+}
+
+// firstReport reports whether a dry run has not reported stopping pid yet, so
+// a blocked process is reported once instead of on every check
+func (p *ProcessPolicyImpl) firstReport(pid int) bool {
+	p.reportedMu.Lock()
+	defer p.reportedMu.Unlock()
+	if p.reported[pid] {
 		return false
 	}
-	allowedTo, err := time.Parse("15:04", appConfig.AllowedTo)
+	p.reported[pid] = true
+	return true
+}
+
+// forgetReported forgets the reported processes that are no longer running
+func (p *ProcessPolicyImpl) forgetReported(runningPids map[int]bool) {
+	p.reportedMu.Lock()
+	defer p.reportedMu.Unlock()
+	for pid := range p.reported {
+		if !runningPids[pid] {
+			delete(p.reported, pid)
+		}
+	}
+}
+
+func (p *ProcessPolicyImpl) isSuspended(pid int) bool {
+	p.suspendedMu.Lock()
+	defer p.suspendedMu.Unlock()
+	_, ok := p.suspended[pid]
+	return ok
+}
+
+// resumeAllowed resumes suspended processes whose rule allows them again or
+// was removed, and forgets the ones that are no longer running
+func (p *ProcessPolicyImpl) resumeAllowed(appsConfig []AppConfig, runningPids map[int]bool, now time.Time) {
+	var toResume []processMatch
+	changed := false
+	p.suspendedMu.Lock()
+	for pid, match := range p.suspended {
+		if !runningPids[pid] {
+			delete(p.suspended, pid)
+			changed = true
+			continue
+		}
+		appConfig, ok := findApp(appsConfig, match.appConfig.Name)
+		if ok && !p.isAllowed(appConfig, now) {
+			continue
+		}
+		delete(p.suspended, pid)
+		changed = true
+		toResume = append(toResume, match.processMatch)
+	}
+	p.suspendedMu.Unlock()
+
+	for _, match := range toResume {
+		p.resume(match)
+	}
+	if changed {
+		p.saveState()
+	}
+}
+
+// resumeSaved resumes the processes a previous run left suspended, e.g.
+// because it crashed. A process whose PID was reused since is left alone.
+// The next check suspends the ones that are still blocked again.
+func (p *ProcessPolicyImpl) resumeSaved() {
+	saved := p.savedSuspended
+	p.savedSuspended = nil
+	if len(saved) == 0 {
+		return
+	}
+	processes, err := p.monitor.GetRunningProcesses()
 	if err != nil {
-		p.logger.Error("Error parsing allowedTo: " + err.Error())
+		p.logger.Error(fmt.Sprintf("Error getting running processes, not resuming the ones suspended before: %v", err))
+		return
+	}
+	byPid := make(map[int]SuspendedProcess, len(saved))
+	for _, s := range saved {
+		byPid[s.Pid] = s
+	}
+	for _, process := range processes {
+		info, err := process.GetInfo()
+		if err != nil {
+			continue
+		}
+		s, ok := byPid[info.Pid]
+		if !ok || s.Name != info.Name {
+			continue
+		}
+		if reporter, ok := process.(ProcessStartReporter); ok && !s.StartTime.IsZero() {
+			if started, err := reporter.StartTime(); err != nil || !started.Equal(s.StartTime) {
+				continue
+			}
+		}
+		p.resume(processMatch{process: process, info: info})
+	}
+}
+
+// resumeAll resumes every process suspended by the policy, so none is left
+// frozen once the policy stops
+func (p *ProcessPolicyImpl) resumeAll() {
+	p.suspendedMu.Lock()
+	suspended := p.suspended
+	p.suspended = make(map[int]suspendedMatch)
+	p.suspendedMu.Unlock()
+
+	for _, match := range suspended {
+		p.resume(match.processMatch)
+	}
+}
+
+func (p *ProcessPolicyImpl) resume(match processMatch) {
+	event := processEvent(EventProcessResumed, match, fmt.Sprintf("Resuming process: %s, PID: %d", match.info.Name, match.info.Pid))
+	if err := match.process.Resume(); err != nil {
+		p.logger.Error(fmt.Sprintf("Error resuming process: %v", err))
+		event.Error = err.Error()
+	}
+	p.publish(event)
+}
+
+func (p *ProcessPolicyImpl) kill(match processMatch) {
+	event := processEvent(EventProcessKilled, match, fmt.Sprintf("Killing process: %s, PID: %d", match.info.Name, match.info.Pid))
+	if err := match.process.Kill(); err != nil {
+		p.logger.Error(fmt.Sprintf("Error killing process: %v", err))
+		event.Error = err.Error()
+	}
+	p.publish(event)
+}
+
+// processEvent creates an event about the process of a match
+func processEvent(kind EventKind, match processMatch, msg string) Event {
+	return Event{Kind: kind, App: match.appConfig.Name, Process: match.info.Name, Pid: match.info.Pid, Message: msg}
+}
+
+// publish timestamps and logs the event, then hands it to the subscribers
+func (p *ProcessPolicyImpl) publish(event Event) {
+	event.Time = p.now()
+	p.logger.Info(event.Message)
+	p.events.Publish(event)
+}
+
+// startTerminating marks a PID as being terminated. It returns false when the
+// PID was already marked.
+func (p *ProcessPolicyImpl) startTerminating(pid int) bool {
+	p.terminatingMu.Lock()
+	defer p.terminatingMu.Unlock()
+	if p.terminating[pid] {
 		return false
 	}
-	allowedFrom = time.Date(now.Year(), now.Month(), now.Day(), allowedFrom.Hour(), allowedFrom.Minute(), 0, 0, now.Location())
-	allowedTo = time.Date(now.Year(), now.Month(), now.Day(), allowedTo.Hour(), allowedTo.Minute(), 0, 0, now.Location())
+	p.terminating[pid] = true
+	return true
+}
+
+func (p *ProcessPolicyImpl) finishTerminating(pid int) {
+	p.terminatingMu.Lock()
+	defer p.terminatingMu.Unlock()
+	delete(p.terminating, pid)
+}
+
+func (p *ProcessPolicyImpl) loadState() {
+	if p.store == nil {
+		return
+	}
+	state, err := p.store.Load()
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("Error loading state, starting with empty usage: %v", err))
+		return
+	}
+	p.usage.restore(state.Usage)
+	p.savedSuspended = state.Suspended
+}
+
+// suspendedSnapshot returns the processes suspended by the policy, to be saved
+func (p *ProcessPolicyImpl) suspendedSnapshot() []SuspendedProcess {
+	p.suspendedMu.Lock()
+	defer p.suspendedMu.Unlock()
+	var suspended []SuspendedProcess
+	for pid, match := range p.suspended {
+		suspended = append(suspended, SuspendedProcess{Pid: pid, Name: match.info.Name, StartTime: match.started})
+	}
+	sort.Slice(suspended, func(i, j int) bool { return suspended[i].Pid < suspended[j].Pid })
+	return suspended
+}
+
+func (p *ProcessPolicyImpl) saveState() {
+	if p.store == nil {
+		return
+	}
+	now := p.now()
+	p.lastSave = now
+	err := p.store.Save(State{SavedAt: now, Usage: p.usage.snapshot(), Suspended: p.suspendedSnapshot()})
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("Error saving state: %v", err))
+	}
+}
 
-	p.logger.Debug(fmt.Sprintf("AllowedFrom: %s, AllowedTo: %s, Now: %s", allowedFrom.Format("15:04"), allowedTo.Format("15:04"), now.Format("15:04")))
+// isAllowed reports whether an app may run now, because of an override or its rule
+func (p *ProcessPolicyImpl) isAllowed(appConfig AppConfig, now time.Time) bool {
+	if _, ok := p.overrideEnd(appConfig.Name, now); ok {
+		return true
+	}
+	return p.allowedByRule(appConfig, now)
+}
 
-	if allowedFrom.After(allowedTo) {
-		// If AllowedFrom is later than AllowedTo, it means the app is allowed to run overnight
-		// So we need to check if the current time is outside the allowed time
-		if now.Before(allowedFrom) && now.After(allowedTo) {
+// allowedByRule combines the time windows and the daily quota of an app. Apps
+// that only have a quota may run at any time until it is used up.
+func (p *ProcessPolicyImpl) allowedByRule(appConfig AppConfig, now time.Time) bool {
+	if appConfig.hasWindows() || appConfig.DailyQuota == "" {
+		if !p.isAllowedToRun(appConfig) {
 			return false
 		}
-	} else {
-		// If AllowedFrom is earlier than AllowedTo, it means the app is allowed to run during the day
-		// So we need to check if the current time is outside the allowed time
-		if now.Before(allowedFrom) || now.After(allowedTo) {
-			return false
+	}
+	return !p.isQuotaExhausted(appConfig, now)
+}
+
+func (p *ProcessPolicyImpl) isQuotaExhausted(appConfig AppConfig, now time.Time) bool {
+	if appConfig.DailyQuota == "" {
+		return false
+	}
+	quota, err := time.ParseDuration(appConfig.DailyQuota)
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("Error parsing daily quota of %s: %v", appConfig.Name, err))
+		return true
+	}
+	if used := p.usage.get(appConfig.Name, now); used >= quota {
+		if p.firstExhaustion(appConfig.Name, now) {
+			p.logger.Info(fmt.Sprintf("Daily quota of %s exhausted for %s", appConfig.DailyQuota, appConfig.Name))
 		}
+		return true
 	}
+	return false
+}
+
+// firstExhaustion reports whether the quota of a rule is found exhausted for
+// the first time in the quota period of now, so it is logged once per period
+// instead of on every check
+func (p *ProcessPolicyImpl) firstExhaustion(name string, now time.Time) bool {
+	period := p.usage.periodStart(now)
+	p.exhaustedMu.Lock()
+	defer p.exhaustedMu.Unlock()
+	if p.exhausted[name].Equal(period) {
+		return false
+	}
+	p.exhausted[name] = period
 	return true
 }
 
+func (p *ProcessPolicyImpl) isAllowedToRun(appConfig AppConfig) bool {
+	now := p.now()
+
+	// Windows that started yesterday may run past midnight into today
+	for _, day := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		for _, window := range appConfig.windowsOn(day.Weekday()) {
+			allowedFrom, allowedTo, err := windowBounds(window, day)
+			if err != nil {
+				p.logger.Error(fmt.Sprintf("Error parsing window of %s: %v", appConfig.Name, err))
+				continue
+			}
+
+			p.logger.Debug(fmt.Sprintf("AllowedFrom: %s, AllowedTo: %s, Now: %s", allowedFrom.Format("Mon 15:04"), allowedTo.Format("Mon 15:04"), now.Format("Mon 15:04")))
+
+			if !now.Before(allowedFrom) && !now.After(allowedTo) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func findApp(appsConfig []AppConfig, name string) (AppConfig, bool) {
+	for _, appConfig := range appsConfig {
+		if appConfig.Name == name {
+			return appConfig, true
+		}
+	}
+	return AppConfig{}, false
+}
+
 func existElementInSlice(slice []string, element string) bool {
 	for _, item := range slice {
 		if item == element {
@@ -2,27 +2,97 @@ package sleego
 
 import (
 	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/joaogabriel01/sleego/clock/fake"
 )
 
 // *************** MOCKS *************** //
 
 // MockProcess is a mock implementation of Process
 type MockProcess struct {
-	info   ProcessInfo
-	killed bool
+	mu              sync.Mutex
+	info            ProcessInfo
+	killed          bool
+	terminated      bool
+	ignoreTerminate bool // keep running after Terminate, like a process that ignores SIGTERM
+	suspended       bool
+	resumed         bool
+	children        []Process
+	parent          Process
+	started         time.Time
 }
 
 func (p *MockProcess) GetInfo() (ProcessInfo, error) {
 	return p.info, nil
 }
 
+func (p *MockProcess) StartTime() (time.Time, error) {
+	return p.started, nil
+}
+
 func (p *MockProcess) Kill() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.killed = true
 	return nil
 }
 
+func (p *MockProcess) Terminate() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.terminated = true
+	return nil
+}
+
+func (p *MockProcess) IsRunning() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.killed && !(p.terminated && !p.ignoreTerminate), nil
+}
+
+func (p *MockProcess) Suspend() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.suspended, p.resumed = true, false
+	return nil
+}
+
+func (p *MockProcess) Resume() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.suspended, p.resumed = false, true
+	return nil
+}
+
+func (p *MockProcess) suspendState() (suspended, resumed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.suspended, p.resumed
+}
+
+func (p *MockProcess) Children() ([]Process, error) {
+	return p.children, nil
+}
+
+func (p *MockProcess) Parent() (Process, error) {
+	if p.parent == nil {
+		return nil, errors.New("process has no parent")
+	}
+	return p.parent, nil
+}
+
+func (p *MockProcess) state() (killed, terminated bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.killed, p.terminated
+}
+
 // MockProcessorMonitor is a mock implementation of ProcessorMonitor
 type MockProcessorMonitor struct {
 	processes []Process
@@ -32,6 +102,20 @@ func (m *MockProcessorMonitor) GetRunningProcesses() ([]Process, error) {
 	return m.processes, nil
 }
 
+// MockWatchingMonitor also reports process starts, like the proc connector
+type MockWatchingMonitor struct {
+	MockProcessorMonitor
+	execs chan Process
+	err   error
+}
+
+func (m *MockWatchingMonitor) WatchExec(ctx context.Context) (<-chan Process, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.execs, nil
+}
+
 type MockCategoryOperator struct{}
 
 func (m *MockCategoryOperator) GetCategoriesOf(processName string) []string {
@@ -44,6 +128,13 @@ func (m *MockCategoryOperator) SetProcessByCategories(categoriesByProcess map[st
 
 var mockCategoryOperator = &MockCategoryOperator{}
 
+// newTestBus returns a bus for a policy under test and a subscription to it
+func newTestBus(buffer int) (*EventBus, <-chan Event) {
+	bus := NewEventBus()
+	ch, _ := bus.Subscribe(buffer)
+	return bus, ch
+}
+
 // *************** TESTS *************** //
 
 // TestEnforceProcessPolicy tests the Apply method
@@ -71,14 +162,14 @@ func TestEnforceProcessPolicy_KillProcessAndVerifyChannel(t *testing.T) {
 		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC) // 18:00 UTC on October 10, 2023
 	}
 
-	ch := make(chan string, 1)
-	policy := NewProcessPolicyImpl(mockMonitor, mockCategoryOperator, mockNow, ch)
+	bus, ch := newTestBus(1)
+	policy := NewProcessPolicyImpl(mockMonitor, mockCategoryOperator, mockNow, bus)
 	policy.enforceProcessPolicy(appsConfig)
 
 	select {
 	case alert := <-ch:
-		if alert != "Killing process: Notepad, PID: 1234" {
-			t.Errorf("Expected alert to be 'Killing process: Notepad', got %s", alert)
+		if alert.Message != "Killing process: Notepad, PID: 1234" {
+			t.Errorf("Expected alert to be 'Killing process: Notepad', got %s", alert.Message)
 		}
 	default:
 		t.Errorf("Expected alert to be sent, but it was not")
@@ -190,6 +281,1026 @@ func TestEnforceProcessPolicy_KillsProcessMatchedByCategory(t *testing.T) {
 	}
 }
 
+func TestEnforceProcessPolicy_KillsProcessOverDailyQuota(t *testing.T) {
+	mockProcess := &MockProcess{
+		info: ProcessInfo{
+			Name: "game.exe",
+			Pid:  1111,
+		},
+	}
+
+	mockMonitor := &MockProcessorMonitor{
+		processes: []Process{mockProcess},
+	}
+
+	appsConfig := []AppConfig{
+		{
+			Name:       "game.exe",
+			DailyQuota: "8s",
+		},
+	}
+
+	current := time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+	mockNow := func() time.Time {
+		return current
+	}
+
+	policy := NewProcessPolicyImpl(mockMonitor, nil, mockNow, nil)
+	for i := 0; i < 2; i++ {
+		policy.enforceProcessPolicy(appsConfig)
+		if mockProcess.killed {
+			t.Fatalf("Process killed after %d checks, before its quota was used", i+1)
+		}
+		current = current.Add(defaultPollInterval)
+	}
+
+	policy.enforceProcessPolicy(appsConfig)
+	if !mockProcess.killed {
+		t.Errorf("Expected process to be killed once its quota was used")
+	}
+}
+
+func TestEnforceProcessPolicy_CategoryMembersShareQuota(t *testing.T) {
+	first := &MockProcess{info: ProcessInfo{Name: "game1.exe", Pid: 301}}
+	second := &MockProcess{info: ProcessInfo{Name: "game2.exe", Pid: 302}}
+
+	mockMonitor := &MockProcessorMonitor{
+		processes: []Process{first, second},
+	}
+
+	categoryOp := newCategoryOperator()
+	categoryOp.SetProcessByCategories(map[string][]string{
+		"games": {"game1.exe", "game2.exe"},
+	})
+
+	appsConfig := []AppConfig{
+		{
+			Name:       "games",
+			DailyQuota: "10s",
+		},
+	}
+
+	current := time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+	policy := NewProcessPolicyImpl(mockMonitor, categoryOp, func() time.Time { return current }, nil)
+
+	policy.enforceProcessPolicy(appsConfig)
+	current = current.Add(defaultPollInterval)
+	policy.enforceProcessPolicy(appsConfig)
+
+	if used := policy.usage.get("games", current); used != defaultPollInterval {
+		t.Errorf("Expected shared usage of %v, got %v", defaultPollInterval, used)
+	}
+	if first.killed || second.killed {
+		t.Errorf("Processes should not be killed before the shared quota is used")
+	}
+}
+
+func TestEnforceProcessPolicy_QuotaResetsAtConfiguredTime(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "game.exe", Pid: 1111}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "game.exe", DailyQuota: "1h"}}
+
+	current := time.Date(2023, 10, 11, 5, 59, 58, 0, time.UTC)
+	quotaReset := time.Date(0, 1, 1, 6, 0, 0, 0, time.UTC)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time { return current }, nil, WithQuotaReset(quotaReset))
+	policy.usage.add("game.exe", time.Hour, current)
+
+	policy.enforceProcessPolicy(appsConfig)
+	if !mockProcess.killed {
+		t.Fatalf("Expected process to be killed while the quota is used up")
+	}
+
+	mockProcess.killed = false
+	current = current.Add(5 * time.Second)
+	policy.enforceProcessPolicy(appsConfig)
+	if mockProcess.killed {
+		t.Errorf("Expected the quota to be available again after the reset time")
+	}
+}
+
+func TestFirstExhaustion_OncePerQuotaPeriod(t *testing.T) {
+	quotaReset := time.Date(0, 1, 1, 6, 0, 0, 0, time.UTC)
+	policy := NewProcessPolicyImpl(&MockProcessorMonitor{}, nil, nil, nil, WithQuotaReset(quotaReset))
+
+	now := time.Date(2023, 10, 11, 5, 0, 0, 0, time.UTC)
+	steps := []struct {
+		now  time.Time
+		want bool
+	}{
+		{now: now, want: true},
+		{now: now.Add(30 * time.Minute), want: false},
+		{now: now.Add(time.Hour), want: true}, // the quota period starts over at 06:00
+		{now: now.Add(2 * time.Hour), want: false},
+	}
+	for _, step := range steps {
+		if got := policy.firstExhaustion("game.exe", step.now); got != step.want {
+			t.Errorf("firstExhaustion() at %v = %v, want %v", step.now.Format("15:04"), got, step.want)
+		}
+	}
+	if !policy.firstExhaustion("other.exe", now.Add(2*time.Hour)) {
+		t.Error("Expected every rule to be logged on its own")
+	}
+}
+
+func TestEnforceProcessPolicy_QuotaDoesNotOverrideWindow(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "game.exe", Pid: 1111}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "game.exe", AllowedFrom: "18:00", AllowedTo: "21:00", DailyQuota: "1h"}}
+
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+	}, nil)
+	policy.enforceProcessPolicy(appsConfig)
+
+	if !mockProcess.killed {
+		t.Errorf("Expected process outside its window to be killed despite remaining quota")
+	}
+}
+
+func TestProcessPolicy_UsageSurvivesRestart(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "game.exe", Pid: 1111}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "game.exe", DailyQuota: "1h"}}
+	store := NewFileStateStore(t.TempDir())
+
+	current := time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+	mockNow := func() time.Time { return current }
+
+	policy := NewProcessPolicyImpl(mockMonitor, nil, mockNow, nil, WithStateStore(store))
+	policy.enforceProcessPolicy(appsConfig)
+	current = current.Add(defaultPollInterval)
+	policy.enforceProcessPolicy(appsConfig)
+	policy.saveState()
+
+	restarted := NewProcessPolicyImpl(mockMonitor, nil, mockNow, nil, WithStateStore(store))
+	if used := restarted.usage.get("game.exe", current); used != defaultPollInterval {
+		t.Errorf("Expected usage of %v after restart, got %v", defaultPollInterval, used)
+	}
+
+	current = current.Add(24 * time.Hour)
+	if used := restarted.usage.get("game.exe", current); used != 0 {
+		t.Errorf("Expected restored usage to reset on the next day, got %v", used)
+	}
+}
+
+func TestProcessPolicy_ResumesSuspendedAfterCrash(t *testing.T) {
+	started := time.Date(2023, 10, 10, 17, 30, 0, 0, time.UTC)
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 1121}, started: started}
+	reused := &MockProcess{info: ProcessInfo{Name: "game", Pid: 1122}, started: started}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{game, reused}}
+	store := NewFileStateStore(t.TempDir())
+	mockNow := func() time.Time { return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC) }
+
+	// The state is saved as soon as the processes are suspended
+	policy := NewProcessPolicyImpl(mockMonitor, nil, mockNow, nil, WithStateStore(store))
+	policy.enforceProcessPolicy([]AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", Action: ActionSuspend}})
+	state, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []SuspendedProcess{{Pid: 1121, Name: "game", StartTime: started}, {Pid: 1122, Name: "game", StartTime: started}}
+	if !reflect.DeepEqual(state.Suspended, want) {
+		t.Fatalf("Saved suspended processes %+v, want %+v", state.Suspended, want)
+	}
+
+	// Sleego crashes without resuming them, and PID 1122 is reused meanwhile
+	reused.started = started.Add(time.Minute)
+	restarted := NewProcessPolicyImpl(mockMonitor, nil, mockNow, nil, WithStateStore(store), WithTicks(make(chan time.Time)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		restarted.Apply(ctx, nil)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	if _, resumed := game.suspendState(); !resumed {
+		t.Error("Expected the process suspended before the crash to be resumed")
+	}
+	if _, resumed := reused.suspendState(); resumed {
+		t.Error("A process reusing a saved PID must not be resumed")
+	}
+	if state, _ := store.Load(); len(state.Suspended) != 0 {
+		t.Errorf("Expected no suspended process left in the state, got %+v", state.Suspended)
+	}
+}
+
+func TestEnforceProcessPolicy_TerminatesGracefully(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "editor", Pid: 2222}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "editor", AllowedFrom: "09:00", AllowedTo: "17:00", GracePeriod: "1s"}}
+
+	bus, ch := newTestBus(2)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, bus)
+	policy.enforceProcessPolicy(appsConfig)
+
+	if alert := <-ch; alert.Message != "Terminating process: editor, PID: 2222, killing it in 1s" {
+		t.Errorf("Unexpected alert: %s", alert.Message)
+	}
+	select {
+	case alert := <-ch:
+		if alert.Message != "Process exited: editor, PID: 2222" {
+			t.Errorf("Unexpected alert: %s", alert.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an alert once the process exited")
+	}
+
+	if killed, terminated := mockProcess.state(); !terminated || killed {
+		t.Errorf("Expected process to be terminated without being killed, got terminated=%v killed=%v", terminated, killed)
+	}
+}
+
+func TestEnforceProcessPolicy_EscalatesToKill(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "game", Pid: 3333}, ignoreTerminate: true}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", GracePeriod: "100ms"}}
+
+	bus, ch := newTestBus(2)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, bus)
+	policy.SetApps(appsConfig)
+	policy.enforceProcessPolicy(appsConfig)
+	<-ch
+
+	// A second check during the grace period must not terminate the process again
+	policy.enforceProcessPolicy(appsConfig)
+
+	select {
+	case alert := <-ch:
+		if alert.Message != "Killing process: game, PID: 3333" {
+			t.Errorf("Unexpected alert: %s", alert.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the process to be killed after its grace period")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if killed, _ := mockProcess.state(); !killed {
+		t.Errorf("Expected process to be killed after ignoring terminate")
+	}
+}
+
+func TestEnforceProcessPolicy_GracePeriodFollowsClock(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "game", Pid: 3334}, ignoreTerminate: true}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", GracePeriod: "1m"}}
+
+	clock := fake.NewClock(time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC))
+	bus, ch := newTestBus(2)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, nil, bus, WithClock(clock))
+	policy.SetApps(appsConfig)
+	policy.enforceProcessPolicy(appsConfig)
+	<-ch
+
+	// The process is checked every terminateCheckInterval until the period is over
+	for elapsed := time.Duration(0); elapsed < time.Minute; elapsed += terminateCheckInterval {
+		clock.BlockUntil(1)
+		if killed, _ := mockProcess.state(); killed {
+			t.Fatalf("Process killed after %v, before its grace period was over", elapsed)
+		}
+		clock.Advance(terminateCheckInterval)
+	}
+
+	select {
+	case alert := <-ch:
+		if alert.Message != "Killing process: game, PID: 3334" {
+			t.Errorf("Unexpected alert: %s", alert.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the process to be killed after its grace period")
+	}
+}
+
+func TestEnforceProcessPolicy_GracePeriodRechecksRule(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(policy *ProcessPolicyImpl)
+	}{
+		{name: "override granted", change: func(policy *ProcessPolicyImpl) {
+			if _, err := policy.Grant("game", time.Hour); err != nil {
+				t.Fatalf("Grant() error = %v", err)
+			}
+		}},
+		{name: "rule relaxed", change: func(policy *ProcessPolicyImpl) {
+			policy.SetApps([]AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "22:00"}})
+		}},
+		{name: "rule removed", change: func(policy *ProcessPolicyImpl) { policy.SetApps(nil) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProcess := &MockProcess{info: ProcessInfo{Name: "game", Pid: 3335}, ignoreTerminate: true}
+			mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+			appsConfig := []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", GracePeriod: "1m"}}
+
+			clock := fake.NewClock(time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC))
+			policy := NewProcessPolicyImpl(mockMonitor, nil, nil, nil, WithClock(clock))
+			policy.SetApps(appsConfig)
+			policy.enforceProcessPolicy(appsConfig)
+
+			clock.BlockUntil(1)
+			tt.change(policy)
+			clock.Advance(time.Minute)
+			waitTerminationDone(t, policy, 3335)
+			if killed, _ := mockProcess.state(); killed {
+				t.Error("Process allowed during its grace period must not be killed")
+			}
+		})
+	}
+}
+
+func TestEnforceProcessPolicy_GracePeriodEndsWithContext(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "game", Pid: 3336}, ignoreTerminate: true}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", GracePeriod: "1m"}}
+
+	clock := fake.NewClock(time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC))
+	policy := NewProcessPolicyImpl(mockMonitor, nil, nil, nil, WithClock(clock), WithTicks(make(chan time.Time)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		policy.Apply(ctx, appsConfig)
+		close(done)
+	}()
+
+	clock.BlockUntil(1)
+	cancel()
+	<-done
+	waitTerminationDone(t, policy, 3336)
+	if killed, _ := mockProcess.state(); killed {
+		t.Error("Process must not be killed once the policy stopped")
+	}
+}
+
+// waitTerminationDone waits until the grace period of pid is no longer tracked
+func waitTerminationDone(t *testing.T, policy *ProcessPolicyImpl, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		policy.terminatingMu.Lock()
+		terminating := policy.terminating[pid]
+		policy.terminatingMu.Unlock()
+		if !terminating {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the grace period of PID %d to be over", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEnforceProcessPolicy_WarnsBeforeWindowEnds(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "game", Pid: 4444}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}}
+
+	current := time.Date(2023, 10, 10, 16, 45, 0, 0, time.UTC)
+	bus, ch := newTestBus(4)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time { return current }, bus, WithKillWarnings([]int{10, 2}))
+
+	expectAlerts := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case got := <-ch:
+				if got.Message != w {
+					t.Errorf("Expected alert %q, got %q", w, got.Message)
+				}
+			default:
+				t.Errorf("Expected alert %q, got none", w)
+			}
+		}
+		select {
+		case got := <-ch:
+			t.Errorf("Unexpected alert %q", got.Message)
+		default:
+		}
+	}
+
+	policy.enforceProcessPolicy(appsConfig)
+	expectAlerts()
+
+	current = time.Date(2023, 10, 10, 16, 51, 0, 0, time.UTC)
+	policy.enforceProcessPolicy(appsConfig)
+	expectAlerts("Process game, PID: 4444 will be closed in 9 minutes")
+
+	current = current.Add(defaultPollInterval)
+	policy.enforceProcessPolicy(appsConfig)
+	expectAlerts()
+
+	current = time.Date(2023, 10, 10, 16, 58, 30, 0, time.UTC)
+	policy.enforceProcessPolicy(appsConfig)
+	expectAlerts("Process game, PID: 4444 will be closed in 2 minutes")
+
+	current = current.Add(defaultPollInterval)
+	policy.enforceProcessPolicy(appsConfig)
+	expectAlerts()
+
+	if mockProcess.killed {
+		t.Errorf("Process should not be killed before its window ends")
+	}
+}
+
+func TestEnforceProcessPolicy_WarnsOnceForOverlappingRules(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "steam", Pid: 7}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{
+		{Name: "steam", AllowedFrom: "09:00", AllowedTo: "20:00"},
+		{Name: "st*", AllowedFrom: "09:00", AllowedTo: "17:00"},
+	}
+
+	current := time.Date(2023, 10, 10, 16, 52, 0, 0, time.UTC)
+	bus, ch := newTestBus(4)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time { return current }, bus, WithKillWarnings([]int{10}))
+	for range 4 {
+		policy.enforceProcessPolicy(appsConfig)
+		current = current.Add(defaultPollInterval)
+	}
+
+	// Only the rule blocking the process first is announced
+	if got := <-ch; got.App != "st*" || got.MinutesRemaining != 8 {
+		t.Errorf("Unexpected warning %+v", got)
+	}
+	select {
+	case got := <-ch:
+		t.Errorf("Expected a single warning, got another %+v", got)
+	default:
+	}
+}
+
+func TestEnforceProcessPolicy_WarnsBeforeQuotaRunsOut(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "game", Pid: 5555}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "game", DailyQuota: "1h"}}
+
+	current := time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+	bus, ch := newTestBus(2)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time { return current }, bus, WithKillWarnings([]int{5}))
+	policy.usage.add("game", 56*time.Minute, current)
+
+	policy.enforceProcessPolicy(appsConfig)
+	current = current.Add(defaultPollInterval)
+	policy.enforceProcessPolicy(appsConfig)
+
+	select {
+	case got := <-ch:
+		if got.Message != "Process game, PID: 5555 will be closed in 4 minutes" {
+			t.Errorf("Unexpected alert %q", got.Message)
+		}
+		if got.Kind != EventKillWarning || got.MinutesRemaining != 4 || got.Scheduled.IsZero() {
+			t.Errorf("Unexpected warning event: %+v", got)
+		}
+	default:
+		t.Fatal("Expected a warning before the quota runs out")
+	}
+	select {
+	case got := <-ch:
+		t.Errorf("Expected a single warning, also got %q", got.Message)
+	default:
+	}
+}
+
+func TestEnforceProcessPolicy_DryRunDoesNotKill(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "Notepad", Pid: 1234}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{
+		{Name: "Notepad", AllowedFrom: "09:00", AllowedTo: "17:00"},
+		{Name: "Notepad", AllowedFrom: "09:00", AllowedTo: "17:00", GracePeriod: "1s"},
+	}
+
+	bus, ch := newTestBus(2)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, bus, WithDryRun())
+	policy.enforceProcessPolicy(appsConfig)
+
+	select {
+	case alert := <-ch:
+		if alert.Message != "Dry run, would kill process: Notepad, PID: 1234" {
+			t.Errorf("Unexpected alert: %s", alert.Message)
+		}
+	default:
+		t.Errorf("Expected a dry run alert for the kill decision")
+	}
+	select {
+	case alert := <-ch:
+		t.Errorf("Expected a single decision per process, also got: %s", alert.Message)
+	default:
+	}
+
+	if killed, terminated := mockProcess.state(); killed || terminated {
+		t.Errorf("Dry run must not stop the process, got killed=%v terminated=%v", killed, terminated)
+	}
+}
+
+func TestEnforceProcessPolicy_SuspendsAndResumes(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 401}}
+	other := &MockProcess{info: ProcessInfo{Name: "other", Pid: 402}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{game, other}}
+	appsConfig := []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", Action: ActionSuspend}}
+
+	now := time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	bus, ch := newTestBus(10)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time { return now }, bus)
+
+	policy.enforceProcessPolicy(appsConfig)
+	policy.enforceProcessPolicy(appsConfig)
+	if suspended, _ := game.suspendState(); !suspended {
+		t.Fatal("Expected the blocked process to be suspended")
+	}
+	if killed, terminated := game.state(); killed || terminated {
+		t.Errorf("Suspended process must not be stopped, got killed=%v terminated=%v", killed, terminated)
+	}
+	if got := <-ch; got.Message != "Suspending process: game, PID: 401" {
+		t.Errorf("Unexpected alert: %s", got.Message)
+	}
+	select {
+	case got := <-ch:
+		t.Errorf("Expected a single suspend alert, also got: %s", got.Message)
+	default:
+	}
+
+	// The window opens again the next morning
+	now = time.Date(2023, 10, 11, 10, 0, 0, 0, time.UTC)
+	policy.enforceProcessPolicy(appsConfig)
+	if suspended, resumed := game.suspendState(); suspended || !resumed {
+		t.Errorf("Expected the process to be resumed, got suspended=%v resumed=%v", suspended, resumed)
+	}
+	if got := <-ch; got.Message != "Resuming process: game, PID: 401" {
+		t.Errorf("Unexpected alert: %s", got.Message)
+	}
+	if _, resumed := other.suspendState(); resumed {
+		t.Error("Only processes suspended by the policy may be resumed")
+	}
+}
+
+func TestEnforceProcessPolicy_ResumesWhenRuleRemoved(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 403}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{game}}
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil)
+
+	policy.enforceProcessPolicy([]AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", Action: ActionSuspend}})
+	policy.enforceProcessPolicy(nil)
+	if suspended, resumed := game.suspendState(); suspended || !resumed {
+		t.Errorf("Expected the process to be resumed, got suspended=%v resumed=%v", suspended, resumed)
+	}
+}
+
+func TestProcessPolicyApply_ResumesOnExit(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 404}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{game}}
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		policy.Apply(ctx, []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", Action: ActionSuspend}})
+		close(done)
+	}()
+	for suspended, _ := game.suspendState(); !suspended; suspended, _ = game.suspendState() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if suspended, resumed := game.suspendState(); suspended || !resumed {
+		t.Errorf("Expected the process to be resumed on exit, got suspended=%v resumed=%v", suspended, resumed)
+	}
+}
+
+func TestEnforceProcessPolicy_DryRunReportsOncePerProcess(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "Notepad", Pid: 1235}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "Notepad", AllowedFrom: "09:00", AllowedTo: "17:00"}}
+
+	bus, ch := newTestBus(10)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, bus, WithDryRun())
+	countReports := func() int {
+		n := 0
+		for {
+			select {
+			case <-ch:
+				n++
+			default:
+				return n
+			}
+		}
+	}
+
+	for range 5 {
+		policy.enforceProcessPolicy(appsConfig)
+	}
+	if n := countReports(); n != 1 {
+		t.Errorf("Expected a single report over 5 checks, got %d", n)
+	}
+
+	// Once the process exits, a new one with its PID is reported again
+	mockMonitor.processes = nil
+	policy.enforceProcessPolicy(appsConfig)
+	mockMonitor.processes = []Process{mockProcess}
+	policy.enforceProcessPolicy(appsConfig)
+	if n := countReports(); n != 1 {
+		t.Errorf("Expected the process to be reported again after it exited, got %d reports", n)
+	}
+}
+
+func TestEnforceProcessPolicy_DryRunDoesNotSuspend(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 405}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{game}}
+	bus, ch := newTestBus(1)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, bus, WithDryRun())
+
+	policy.enforceProcessPolicy([]AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", Action: ActionSuspend}})
+	if got := <-ch; got.Message != "Dry run, would suspend process: game, PID: 405" {
+		t.Errorf("Unexpected alert: %s", got.Message)
+	}
+	if suspended, _ := game.suspendState(); suspended {
+		t.Error("Dry run must not suspend the process")
+	}
+}
+
+func TestProcessPolicyApply_StopsProcessOnExec(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 501}}
+	allowed := &MockProcess{info: ProcessInfo{Name: "editor", Pid: 502}}
+	monitor := &MockWatchingMonitor{execs: make(chan Process)}
+	policy := NewProcessPolicyImpl(monitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go policy.Apply(ctx, []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}})
+
+	// Neither process is in the monitor, so only the events can stop them
+	monitor.execs <- allowed
+	monitor.execs <- game
+	deadline := time.Now().Add(time.Second)
+	for killed, _ := game.state(); !killed; killed, _ = game.state() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the process to be killed on exec, before the next poll")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if killed, terminated := allowed.state(); killed || terminated {
+		t.Errorf("Allowed process must not be stopped, got killed=%v terminated=%v", killed, terminated)
+	}
+}
+
+func TestProcessPolicyApply_PollsWithoutEvents(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 503}}
+	monitor := &MockWatchingMonitor{MockProcessorMonitor: MockProcessorMonitor{processes: []Process{game}}, err: errors.New("unavailable")}
+	policy := NewProcessPolicyImpl(monitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		policy.Apply(ctx, []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}})
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for killed, _ := game.state(); !killed; killed, _ = game.state() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected polling to kill the process")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestProcessPolicyApply_PollsBetweenEvents(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 504}}
+	editor := &MockProcess{info: ProcessInfo{Name: "editor", Pid: 505}}
+	monitor := &MockWatchingMonitor{MockProcessorMonitor: MockProcessorMonitor{processes: []Process{game}}, execs: make(chan Process)}
+	clock := fake.NewClock(time.Date(2023, 10, 10, 16, 59, 58, 0, time.UTC))
+	policy := NewProcessPolicyImpl(monitor, nil, nil, nil, WithClock(clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go policy.Apply(ctx, []AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}})
+
+	// A steady stream of process starts must not hold back the next poll
+	clock.BlockUntil(1)
+	for range defaultPollInterval / time.Second {
+		monitor.execs <- editor
+		clock.Advance(time.Second)
+	}
+	deadline := time.Now().Add(time.Second)
+	for killed, _ := game.state(); !killed; killed, _ = game.state() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the poll to kill the process despite the events")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEnforceProcessPolicy_PublishesTypedEvents(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "steam", Pid: 701}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	now := time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+
+	bus, ch := newTestBus(1)
+	policy := NewProcessPolicyImpl(mockMonitor, mockCategoryOperator, func() time.Time { return now }, bus)
+	policy.enforceProcessPolicy([]AppConfig{{Name: "MockCategory", AllowedFrom: "09:00", AllowedTo: "17:00"}})
+
+	want := Event{Kind: EventProcessKilled, Time: now, App: "MockCategory", Process: "steam", Pid: 701, Message: "Killing process: steam, PID: 701"}
+	if got := <-ch; got != want {
+		t.Errorf("Event = %+v, want %+v", got, want)
+	}
+}
+
+func TestEnforceProcessPolicy_DoesNotBlockOnSlowSubscriber(t *testing.T) {
+	var processes []Process
+	for pid := 702; pid < 712; pid++ {
+		processes = append(processes, &MockProcess{info: ProcessInfo{Name: "game", Pid: pid}})
+	}
+	mockMonitor := &MockProcessorMonitor{processes: processes}
+
+	// Nobody reads from the subscription, which only has room for one event
+	bus, _ := newTestBus(1)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, bus)
+
+	done := make(chan struct{})
+	go func() {
+		policy.enforceProcessPolicy([]AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Policy blocked on a subscriber that doesn't read its events")
+	}
+	for _, process := range processes {
+		if killed, _ := process.(*MockProcess).state(); !killed {
+			t.Errorf("Expected every process to be killed")
+		}
+	}
+}
+
+func TestEnforceProcessPolicy_KillsProcessesMatchedByPattern(t *testing.T) {
+	chrome := &MockProcess{info: ProcessInfo{Name: "chrome", Pid: 101}}
+	crashpad := &MockProcess{info: ProcessInfo{Name: "chrome_crashpad_handler", Pid: 102}}
+	game := &MockProcess{info: ProcessInfo{Name: "game-v2.3", Pid: 103}}
+	other := &MockProcess{info: ProcessInfo{Name: "gamescope", Pid: 104}}
+
+	mockMonitor := &MockProcessorMonitor{
+		processes: []Process{chrome, crashpad, game, other},
+	}
+
+	appsConfig := []AppConfig{
+		{Name: "chrome*", AllowedFrom: "09:00", AllowedTo: "17:00"},
+		{Name: `re:^game-v[0-9.]+$`, AllowedFrom: "09:00", AllowedTo: "17:00"},
+	}
+
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil)
+	policy.SetApps(appsConfig)
+	policy.enforceProcessPolicy(policy.Apps())
+
+	for _, process := range []*MockProcess{chrome, crashpad, game} {
+		if !process.killed {
+			t.Errorf("Expected %s to be killed", process.info.Name)
+		}
+	}
+	if other.killed {
+		t.Errorf("Expected %s not to be killed", other.info.Name)
+	}
+}
+
+func TestEnforceProcessPolicy_MatchesCmdlineExeAndUser(t *testing.T) {
+	minecraft := &MockProcess{info: ProcessInfo{Name: "java", Pid: 201, Cmdline: "/usr/bin/java -jar /opt/minecraft/launcher.jar", Username: "kid"}}
+	ide := &MockProcess{info: ProcessInfo{Name: "java", Pid: 202, Cmdline: "/usr/bin/java -jar /opt/idea/idea.jar", Username: "kid"}}
+	parentMinecraft := &MockProcess{info: ProcessInfo{Name: "java", Pid: 203, Cmdline: "/usr/bin/java -jar /opt/minecraft/launcher.jar", Username: "parent"}}
+	factorio := &MockProcess{info: ProcessInfo{Name: "factorio", Pid: 204, Exe: "/home/kid/Games/factorio/bin/factorio"}}
+	renamed := &MockProcess{info: ProcessInfo{Name: "homework", Pid: 205, Exe: "/home/kid/Games/factorio/bin/homework"}}
+	unreadable := &MockProcess{info: ProcessInfo{Name: "factorio", Pid: 206}}
+
+	mockMonitor := &MockProcessorMonitor{
+		processes: []Process{minecraft, ide, parentMinecraft, factorio, renamed, unreadable},
+	}
+
+	appsConfig := []AppConfig{
+		{Name: "java", AllowedFrom: "09:00", AllowedTo: "17:00", Match: &ProcessMatch{Cmdline: "*minecraft*", User: "kid"}},
+		{Name: "*", AllowedFrom: "09:00", AllowedTo: "17:00", Match: &ProcessMatch{Exe: "/home/kid/Games/*"}},
+	}
+
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil)
+	policy.SetApps(appsConfig)
+	policy.enforceProcessPolicy(policy.Apps())
+
+	for _, process := range []*MockProcess{minecraft, factorio, renamed} {
+		if !process.killed {
+			t.Errorf("Expected %s, PID %d to be killed", process.info.Name, process.info.Pid)
+		}
+	}
+	for _, process := range []*MockProcess{ide, parentMinecraft, unreadable} {
+		if process.killed {
+			t.Errorf("Expected %s, PID %d not to be killed", process.info.Name, process.info.Pid)
+		}
+	}
+}
+
+// MockDetailedProcess leaves its details out of GetInfo, like ProcessImpl,
+// and records which ones are read
+type MockDetailedProcess struct {
+	MockProcess
+	details ProcessInfo
+	read    []string
+}
+
+func (p *MockDetailedProcess) Exe() (string, error) {
+	p.read = append(p.read, "exe")
+	return p.details.Exe, nil
+}
+
+func (p *MockDetailedProcess) Cmdline() (string, error) {
+	p.read = append(p.read, "cmdline")
+	return p.details.Cmdline, nil
+}
+
+func (p *MockDetailedProcess) Username() (string, error) {
+	p.read = append(p.read, "user")
+	return p.details.Username, nil
+}
+
+func TestEnforceProcessPolicy_ReadsDetailsOnlyForMatchingRules(t *testing.T) {
+	details := ProcessInfo{Exe: "/usr/bin/java", Cmdline: "/usr/bin/java -jar /opt/minecraft/launcher.jar", Username: "kid"}
+	minecraft := &MockDetailedProcess{MockProcess: MockProcess{info: ProcessInfo{Name: "java", Pid: 211}}, details: details}
+	editor := &MockDetailedProcess{MockProcess: MockProcess{info: ProcessInfo{Name: "editor", Pid: 212}}, details: details}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{minecraft, editor}}
+
+	appsConfig := []AppConfig{
+		{Name: "java", AllowedFrom: "09:00", AllowedTo: "17:00", Match: &ProcessMatch{Cmdline: "*minecraft*"}},
+		{Name: "java", AllowedFrom: "09:00", AllowedTo: "17:00", Match: &ProcessMatch{Cmdline: "*idea*"}},
+	}
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil)
+	policy.SetApps(appsConfig)
+	policy.enforceProcessPolicy(policy.Apps())
+
+	if !minecraft.killed {
+		t.Error("Expected the process matching the command line to be killed")
+	}
+	// The command line is read once and reused for the second rule
+	if want := []string{"cmdline"}; !reflect.DeepEqual(minecraft.read, want) {
+		t.Errorf("Read %v, want %v", minecraft.read, want)
+	}
+	if len(editor.read) != 0 {
+		t.Errorf("Expected no details read for a process no rule names, got %v", editor.read)
+	}
+}
+
+func TestEnforceProcessPolicy_KillsProcessTree(t *testing.T) {
+	crashHandler := &MockProcess{info: ProcessInfo{Name: "crashpad", Pid: 12}}
+	helper := &MockProcess{info: ProcessInfo{Name: "game-helper", Pid: 11}, children: []Process{crashHandler}}
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 10}, children: []Process{helper}}
+	steam := &MockProcess{info: ProcessInfo{Name: "steam", Pid: 9}, children: []Process{game}}
+	game.parent = steam
+	unrelated := &MockProcess{info: ProcessInfo{Name: "editor", Pid: 20}}
+
+	mockMonitor := &MockProcessorMonitor{processes: []Process{steam, game, helper, crashHandler, unrelated}}
+	mockNow := func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}
+
+	bus, ch := newTestBus(8)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, mockNow, bus)
+	policy.enforceProcessPolicy([]AppConfig{
+		{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", KillTree: true, KillParent: true},
+	})
+
+	for _, process := range []*MockProcess{steam, game, helper, crashHandler} {
+		if !process.killed {
+			t.Errorf("Expected %s to be killed", process.info.Name)
+		}
+	}
+	if unrelated.killed {
+		t.Errorf("Expected %s not to be killed", unrelated.info.Name)
+	}
+
+	want := []string{
+		"Killing process: steam, PID: 9",
+		"Killing process: game, PID: 10",
+		"Killing process: game-helper, PID: 11",
+		"Killing process: crashpad, PID: 12",
+	}
+	for _, w := range want {
+		if got := <-ch; got.Message != w {
+			t.Errorf("Expected alert %q, got %q", w, got.Message)
+		}
+	}
+}
+
+func TestEnforceProcessPolicy_KillsOnlyMatchedProcessByDefault(t *testing.T) {
+	helper := &MockProcess{info: ProcessInfo{Name: "game-helper", Pid: 11}}
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 10}, children: []Process{helper}}
+	steam := &MockProcess{info: ProcessInfo{Name: "steam", Pid: 9}, children: []Process{game}}
+	game.parent = steam
+
+	mockMonitor := &MockProcessorMonitor{processes: []Process{steam, game, helper}}
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil)
+	policy.enforceProcessPolicy([]AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"}})
+
+	if !game.killed {
+		t.Errorf("Expected game to be killed")
+	}
+	if steam.killed || helper.killed {
+		t.Errorf("Expected parent and children to be left alone without kill_tree/kill_parent")
+	}
+}
+
+func TestEnforceProcessPolicy_SkipsProtectedProcesses(t *testing.T) {
+	sshd := &MockProcess{info: ProcessInfo{Name: "sshd", Pid: 700}}
+	agent := &MockProcess{info: ProcessInfo{Name: "backup-agent", Pid: 701}}
+	pinned := &MockProcess{info: ProcessInfo{Name: "pinned", Pid: 702}}
+	self := &MockProcess{info: ProcessInfo{Name: "sleego", Pid: os.Getpid()}}
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 703}}
+
+	mockMonitor := &MockProcessorMonitor{processes: []Process{sshd, agent, pinned, self, game}}
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil, WithProtected(ProtectedConfig{Names: []string{"backup-agent"}, Pids: []int{702}}))
+	policy.enforceProcessPolicy([]AppConfig{{Name: "*", AllowedFrom: "09:00", AllowedTo: "17:00"}})
+
+	for _, process := range []*MockProcess{sshd, agent, pinned, self} {
+		if process.killed {
+			t.Errorf("Expected protected %s to be skipped", process.info.Name)
+		}
+	}
+	if !game.killed {
+		t.Errorf("Expected game to be killed")
+	}
+}
+
+func TestEnforceProcessPolicy_DoesNotKillProtectedParent(t *testing.T) {
+	systemd := &MockProcess{info: ProcessInfo{Name: "systemd", Pid: 1}}
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 800}, parent: systemd}
+
+	mockMonitor := &MockProcessorMonitor{processes: []Process{game}}
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time {
+		return time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	}, nil)
+	policy.enforceProcessPolicy([]AppConfig{{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00", KillParent: true}})
+
+	if !game.killed {
+		t.Errorf("Expected game to be killed")
+	}
+	if systemd.killed {
+		t.Errorf("Expected the protected parent not to be killed")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	game := &MockProcess{info: ProcessInfo{Name: "game", Pid: 601}}
+	editor := &MockProcess{info: ProcessInfo{Name: "editor", Pid: 602}}
+	other := &MockProcess{info: ProcessInfo{Name: "other", Pid: 603}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{game, editor, other}}
+	now := time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, func() time.Time { return now }, nil)
+	policy.SetApps([]AppConfig{
+		{Name: "game", AllowedFrom: "09:00", AllowedTo: "17:00"},
+		{Name: "editor", AllowedFrom: "09:00", AllowedTo: "20:00"},
+	})
+
+	got, err := policy.Matches()
+	if err != nil {
+		t.Fatalf("Matches() error = %v", err)
+	}
+	want := []MatchedProcess{
+		{App: "game", Process: "game", Pid: 601},
+		{App: "editor", Process: "editor", Pid: 602, Allowed: true, BlockedAt: time.Date(2023, 10, 10, 20, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Matches() = %+v, want %+v", got, want)
+	}
+	if killed, _ := game.state(); killed {
+		t.Error("Matches() must not stop any process")
+	}
+}
+
 func TestIsAllowedToRun_InvalidTimeFormat(t *testing.T) {
 	appConfig := AppConfig{
 		AllowedFrom: "invalid",
@@ -287,6 +1398,84 @@ func TestApply_ProcessAllowed(t *testing.T) {
 		t.Errorf("Apply should not have killed the process")
 	}
 }
+
+func TestApply_ChecksOnEachTick(t *testing.T) {
+	mockProcess := &MockProcess{info: ProcessInfo{Name: "game", Pid: 601}}
+	mockMonitor := &MockProcessorMonitor{processes: []Process{mockProcess}}
+	appsConfig := []AppConfig{{Name: "game", DailyQuota: "10s"}}
+
+	var mu sync.Mutex
+	current := time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		current = current.Add(d)
+	}
+	mockNow := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	}
+
+	ticks := make(chan time.Time)
+	policy := NewProcessPolicyImpl(mockMonitor, nil, mockNow, nil, WithTicks(ticks))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		policy.Apply(ctx, appsConfig)
+		close(done)
+	}()
+
+	// Each tick is only received once the previous check has finished
+	ticks <- time.Time{}
+	advance(defaultPollInterval)
+	ticks <- time.Time{}
+	if killed, _ := mockProcess.state(); killed {
+		t.Fatal("Process must not be killed before its quota is used up")
+	}
+	advance(defaultPollInterval)
+	ticks <- time.Time{}
+	ticks <- time.Time{}
+	cancel()
+	<-done
+
+	if killed, _ := mockProcess.state(); !killed {
+		t.Errorf("Expected the process to be killed once its quota was used up")
+	}
+}
+
+func TestApply_StopsWhenContextCancelled(t *testing.T) {
+	policy := NewProcessPolicyImpl(&MockProcessorMonitor{}, nil, nil, nil, WithPollInterval(time.Minute))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		policy.Apply(ctx, nil)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Apply to return right after the context was cancelled")
+	}
+}
+
+func TestSetPollInterval(t *testing.T) {
+	policy := NewProcessPolicyImpl(nil, nil, nil, nil, WithPollInterval(2*time.Second))
+	if got := policy.PollInterval(); got != 2*time.Second {
+		t.Errorf("Expected poll interval of 2s, got %v", got)
+	}
+	policy.SetPollInterval(10 * time.Second)
+	if got := policy.PollInterval(); got != 10*time.Second {
+		t.Errorf("Expected poll interval of 10s, got %v", got)
+	}
+	policy.SetPollInterval(0)
+	if got := policy.PollInterval(); got != defaultPollInterval {
+		t.Errorf("Expected the default poll interval, got %v", got)
+	}
+}
+
 func TestIsAllowedToRun(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -402,6 +1591,104 @@ func TestIsAllowedToRun(t *testing.T) {
 			mockNow:  time.Date(2023, 10, 10, 10, 0, 0, 0, time.UTC), // 10:00 UTC on October 10, 2023
 			expected: false,
 		},
+		{
+			name: "Weekday schedule within allowed time",
+			appConfig: AppConfig{
+				Schedule: map[string]TimeWindows{
+					"mon-fri": {{AllowedFrom: "18:00", AllowedTo: "20:00"}},
+					"sat,sun": {{AllowedFrom: "09:00", AllowedTo: "22:00"}},
+				},
+			},
+			mockNow:  time.Date(2023, 10, 10, 19, 0, 0, 0, time.UTC), // Tuesday 19:00
+			expected: true,
+		},
+		{
+			name: "Weekday schedule outside allowed time",
+			appConfig: AppConfig{
+				Schedule: map[string]TimeWindows{
+					"mon-fri": {{AllowedFrom: "18:00", AllowedTo: "20:00"}},
+					"sat,sun": {{AllowedFrom: "09:00", AllowedTo: "22:00"}},
+				},
+			},
+			mockNow:  time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC), // Tuesday 12:00
+			expected: false,
+		},
+		{
+			name: "Weekend schedule within allowed time",
+			appConfig: AppConfig{
+				Schedule: map[string]TimeWindows{
+					"mon-fri": {{AllowedFrom: "18:00", AllowedTo: "20:00"}},
+					"sat,sun": {{AllowedFrom: "09:00", AllowedTo: "22:00"}},
+				},
+			},
+			mockNow:  time.Date(2023, 10, 14, 12, 0, 0, 0, time.UTC), // Saturday 12:00
+			expected: true,
+		},
+		{
+			name: "Weekday not in schedule falls back to allowed window",
+			appConfig: AppConfig{
+				AllowedFrom: "09:00",
+				AllowedTo:   "17:00",
+				Schedule: map[string]TimeWindows{
+					"sat,sun": {{AllowedFrom: "18:00", AllowedTo: "22:00"}},
+				},
+			},
+			mockNow:  time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC), // Tuesday 12:00
+			expected: true,
+		},
+		{
+			name: "Weekday not in schedule without fallback",
+			appConfig: AppConfig{
+				Schedule: map[string]TimeWindows{
+					"sat,sun": {{AllowedFrom: "00:00", AllowedTo: "23:59"}},
+				},
+			},
+			mockNow:  time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC), // Tuesday 12:00
+			expected: false,
+		},
+		{
+			name: "Within one of multiple windows",
+			appConfig: AppConfig{
+				Windows: []TimeWindow{
+					{AllowedFrom: "07:00", AllowedTo: "08:00"},
+					{AllowedFrom: "18:00", AllowedTo: "21:00"},
+				},
+			},
+			mockNow:  time.Date(2023, 10, 10, 19, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name: "Between multiple windows",
+			appConfig: AppConfig{
+				Windows: []TimeWindow{
+					{AllowedFrom: "07:00", AllowedTo: "08:00"},
+					{AllowedFrom: "18:00", AllowedTo: "21:00"},
+				},
+			},
+			mockNow:  time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+		{
+			name: "Overnight window from previous weekday",
+			appConfig: AppConfig{
+				Schedule: map[string]TimeWindows{
+					"fri": {{AllowedFrom: "22:00", AllowedTo: "02:00"}},
+					"sat": {{AllowedFrom: "10:00", AllowedTo: "12:00"}},
+				},
+			},
+			mockNow:  time.Date(2023, 10, 14, 1, 0, 0, 0, time.UTC), // Saturday 01:00
+			expected: true,
+		},
+		{
+			name: "Overnight window does not apply the morning it starts",
+			appConfig: AppConfig{
+				Schedule: map[string]TimeWindows{
+					"fri": {{AllowedFrom: "22:00", AllowedTo: "02:00"}},
+				},
+			},
+			mockNow:  time.Date(2023, 10, 13, 1, 0, 0, 0, time.UTC), // Friday 01:00
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -1,6 +1,9 @@
 package sleego
 
 import (
+	"context"
+	"time"
+
 	"github.com/shirou/gopsutil/v4/process"
 )
 
@@ -8,6 +11,32 @@ import (
 type Process interface {
 	GetInfo() (ProcessInfo, error)
 	Kill() error
+	// Terminate asks the process to exit (SIGTERM or the platform equivalent)
+	Terminate() error
+	IsRunning() (bool, error)
+	// Children returns the direct children of the process
+	Children() ([]Process, error)
+	// Parent returns the process that started this one
+	Parent() (Process, error)
+	// Suspend freezes the process (SIGSTOP or the platform equivalent)
+	Suspend() error
+	// Resume continues a suspended process (SIGCONT or the platform equivalent)
+	Resume() error
+}
+
+// ProcessDetailer is implemented by processes whose GetInfo leaves out the
+// executable, command line and owner, as reading them is slower and needs more
+// privileges. They are then only read for rules that match on them.
+type ProcessDetailer interface {
+	Exe() (string, error)
+	Cmdline() (string, error)
+	Username() (string, error)
+}
+
+// ProcessStartReporter is implemented by processes that know when they
+// started, which tells them apart from later processes reusing their PID
+type ProcessStartReporter interface {
+	StartTime() (time.Time, error)
 }
 
 // ProcessorMonitor will be used to interact with the system processes
@@ -15,11 +44,22 @@ type ProcessorMonitor interface {
 	GetRunningProcesses() ([]Process, error)
 }
 
+// ProcessWatcher is implemented by monitors that are told when a process
+// starts, so it can be checked right away instead of on the next poll
+type ProcessWatcher interface {
+	// WatchExec sends each process that runs a new program until ctx is
+	// done. It fails when the system can't report process events.
+	WatchExec(ctx context.Context) (<-chan Process, error)
+}
+
 // ProcessInfo contains the information of a process
 type ProcessInfo struct {
 	Name     string
 	Pid      int
 	Category []string
+	Exe      string // Exe is the path of the executable, empty if it can't be read or isn't read yet
+	Cmdline  string // Cmdline is the full command line, empty if it can't be read or isn't read yet
+	Username string // Username is the owner of the process, empty if it can't be read or isn't read yet
 }
 
 // This is the adapter to the Process interface from the gopsutil library
@@ -33,21 +73,82 @@ func (p *ProcessImpl) GetInfo() (ProcessInfo, error) {
 	if err != nil {
 		return ProcessInfo{}, err
 	}
-	pid := int(p.proc.Pid)
+	info := ProcessInfo{Name: name, Pid: int(p.proc.Pid)}
 	if p.categoryOperator != nil {
 		categories := p.categoryOperator.GetCategoriesOf(name)
 		if len(categories) != 0 {
-			return ProcessInfo{Name: name, Pid: pid, Category: categories}, nil
+			info.Category = categories
 		}
 	}
+	return info, nil
+}
+
+// Exe returns the path of the executable. Like Cmdline and Username, it
+// needs more privileges than the name, e.g. for processes of other users, so
+// GetInfo leaves it out.
+func (p *ProcessImpl) Exe() (string, error) {
+	return p.proc.Exe()
+}
+
+// Cmdline returns the full command line
+func (p *ProcessImpl) Cmdline() (string, error) {
+	return p.proc.Cmdline()
+}
 
-	return ProcessInfo{Name: name, Pid: pid}, nil
+// Username returns the owner of the process
+func (p *ProcessImpl) Username() (string, error) {
+	return p.proc.Username()
+}
+
+// StartTime returns when the process was created
+func (p *ProcessImpl) StartTime() (time.Time, error) {
+	ms, err := p.proc.CreateTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
 }
 
 func (p *ProcessImpl) Kill() error {
 	return p.proc.Kill()
 }
 
+func (p *ProcessImpl) Terminate() error {
+	return p.proc.Terminate()
+}
+
+func (p *ProcessImpl) IsRunning() (bool, error) {
+	return p.proc.IsRunning()
+}
+
+func (p *ProcessImpl) Suspend() error {
+	return p.proc.Suspend()
+}
+
+func (p *ProcessImpl) Resume() error {
+	return p.proc.Resume()
+}
+
+func (p *ProcessImpl) Children() ([]Process, error) {
+	procs, err := p.proc.Children()
+	if err != nil {
+		return nil, err
+	}
+	children := make([]Process, 0, len(procs))
+	for _, proc := range procs {
+		children = append(children, &ProcessImpl{proc: proc, categoryOperator: p.categoryOperator})
+	}
+	return children, nil
+}
+
+func (p *ProcessImpl) Parent() (Process, error) {
+	proc, err := p.proc.Parent()
+	if err != nil {
+		return nil, err
+	}
+	return &ProcessImpl{proc: proc, categoryOperator: p.categoryOperator}, nil
+}
+
 // This is the adapter to the ProcessorMonitor interface from the gopsutil library
 type ProcessorMonitorImpl struct {
 }
@@ -64,6 +165,11 @@ func (p *ProcessorMonitorImpl) GetRunningProcesses() ([]Process, error) {
 	return processes, nil
 }
 
+// WatchExec uses the proc connector on Linux and fails everywhere else
+func (p *ProcessorMonitorImpl) WatchExec(ctx context.Context) (<-chan Process, error) {
+	return watchExec(ctx)
+}
+
 func newProcessWithCategoryOperator(proc *process.Process) *ProcessImpl {
 	return &ProcessImpl{
 		proc:             proc,
@@ -72,4 +178,7 @@ func newProcessWithCategoryOperator(proc *process.Process) *ProcessImpl {
 }
 
 var _ Process = &ProcessImpl{}
+var _ ProcessDetailer = &ProcessImpl{}
+var _ ProcessStartReporter = &ProcessImpl{}
 var _ ProcessorMonitor = &ProcessorMonitorImpl{}
+var _ ProcessWatcher = &ProcessorMonitorImpl{}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package sleego
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"golang.org/x/sys/unix"
+)
+
+// Proc connector constants from linux/connector.h and linux/cn_proc.h, which
+// golang.org/x/sys doesn't define
+const (
+	cnIdxProc         = 0x1
+	cnValProc         = 0x1
+	procCnMcastListen = 0x1
+	procEventExec     = 0x2
+
+	sizeofCnMsg = 20 // struct cn_msg without its payload
+	// Offsets inside struct proc_event
+	procEventWhat     = 0
+	procEventExecTgid = 20
+	sizeofProcEvent   = 24 // large enough for an exec event
+)
+
+// watchExec subscribes to the proc connector, which reports every exec over
+// netlink. It needs CAP_NET_ADMIN and a kernel with CONFIG_PROC_EVENTS.
+func watchExec(ctx context.Context) (<-chan Process, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_CONNECTOR)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	addr := &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: cnIdxProc}
+	if err := unix.Bind(fd, addr); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	if err := unix.Sendto(fd, listenRequest(), 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("sendto", err)
+	}
+
+	// As with inotify, the runtime poller lets Close unblock the pending Read
+	file := os.NewFile(uintptr(fd), "proc-connector")
+	out := make(chan Process)
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+	go func() {
+		defer close(out)
+		buf := make([]byte, os.Getpagesize())
+		for {
+			n, err := file.Read(buf)
+			if errors.Is(err, unix.ENOBUFS) {
+				// Events were dropped under load, the next poll catches up
+				continue
+			}
+			if err != nil {
+				return
+			}
+			for _, pid := range parseExecEvents(buf[:n]) {
+				proc, err := process.NewProcess(int32(pid))
+				if err != nil {
+					// Already gone
+					continue
+				}
+				select {
+				case out <- newProcessWithCategoryOperator(proc):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// listenRequest builds the netlink message that subscribes to process events
+func listenRequest() []byte {
+	const size = unix.SizeofNlMsghdr + sizeofCnMsg + 4
+	msg := make([]byte, size)
+	binary.NativeEndian.PutUint32(msg[0:], size)
+	binary.NativeEndian.PutUint16(msg[4:], unix.NLMSG_DONE)
+	binary.NativeEndian.PutUint32(msg[12:], uint32(os.Getpid()))
+
+	cn := msg[unix.SizeofNlMsghdr:]
+	binary.NativeEndian.PutUint32(cn[0:], cnIdxProc)
+	binary.NativeEndian.PutUint32(cn[4:], cnValProc)
+	binary.NativeEndian.PutUint16(cn[16:], 4)
+	binary.NativeEndian.PutUint32(cn[sizeofCnMsg:], procCnMcastListen)
+	return msg
+}
+
+// parseExecEvents returns the PIDs of the processes that ran exec in a
+// datagram from the proc connector. Other events are ignored.
+func parseExecEvents(buf []byte) []int {
+	msgs, err := syscall.ParseNetlinkMessage(buf)
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, msg := range msgs {
+		if len(msg.Data) < sizeofCnMsg+sizeofProcEvent {
+			continue
+		}
+		event := msg.Data[sizeofCnMsg:]
+		if binary.NativeEndian.Uint32(event[procEventWhat:]) != procEventExec {
+			continue
+		}
+		pids = append(pids, int(binary.NativeEndian.Uint32(event[procEventExecTgid:])))
+	}
+	return pids
+}
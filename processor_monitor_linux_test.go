@@ -0,0 +1,82 @@
+//go:build linux
+
+package sleego
+
+import (
+	"context"
+	"encoding/binary"
+	"os/exec"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func execEvent(what uint32, tgid uint32) []byte {
+	size := unix.SizeofNlMsghdr + sizeofCnMsg + sizeofProcEvent
+	msg := make([]byte, size)
+	binary.NativeEndian.PutUint32(msg[0:], uint32(size))
+	binary.NativeEndian.PutUint16(msg[4:], unix.NLMSG_DONE)
+	event := msg[unix.SizeofNlMsghdr+sizeofCnMsg:]
+	binary.NativeEndian.PutUint32(event[procEventWhat:], what)
+	binary.NativeEndian.PutUint32(event[procEventExecTgid-4:], tgid+1)
+	binary.NativeEndian.PutUint32(event[procEventExecTgid:], tgid)
+	return msg
+}
+
+func TestParseExecEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		want []int
+	}{
+		{name: "exec", buf: execEvent(procEventExec, 4242), want: []int{4242}},
+		{name: "fork is ignored", buf: execEvent(0x1, 4242)},
+		{name: "several messages", buf: append(execEvent(procEventExec, 1), execEvent(procEventExec, 2)...), want: []int{1, 2}},
+		{name: "truncated", buf: execEvent(procEventExec, 4242)[:30]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseExecEvents(tt.buf)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseExecEvents() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseExecEvents() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestWatchExec(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	execs, err := (&ProcessorMonitorImpl{}).WatchExec(ctx)
+	if err != nil {
+		t.Skipf("Proc connector unavailable: %v", err)
+	}
+
+	cmd := exec.Command("sleep", "5")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Error starting process: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case process := <-execs:
+			info, err := process.GetInfo()
+			if err == nil && info.Pid == cmd.Process.Pid {
+				if info.Name != "sleep" {
+					t.Errorf("Expected name sleep, got %s", info.Name)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("Expected an exec event for the started process")
+		}
+	}
+}
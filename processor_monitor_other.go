@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sleego
+
+import (
+	"context"
+	"errors"
+)
+
+// watchExec has no event source outside Linux, so the policy keeps polling
+func watchExec(ctx context.Context) (<-chan Process, error) {
+	return nil, errors.New("process events are not supported on this platform")
+}
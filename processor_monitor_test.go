@@ -2,6 +2,7 @@ package sleego
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/shirou/gopsutil/v4/process"
@@ -33,6 +34,33 @@ func TestProcessImpl_GetInfo(t *testing.T) {
 	if info.Name != name {
 		t.Errorf("Expected Name %s, got %s", name, info.Name)
 	}
+
+	// The details are only read when a rule matches on them
+	if info.Exe != "" || info.Cmdline != "" || info.Username != "" {
+		t.Errorf("Expected GetInfo to leave out the details, got %+v", info)
+	}
+}
+
+func TestProcessImpl_Details(t *testing.T) {
+	proc, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		t.Fatalf("Failed to create process: %v", err)
+	}
+	p := &ProcessImpl{proc: proc}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable() returned error: %v", err)
+	}
+	if got, _ := p.Exe(); got != exe {
+		t.Errorf("Expected Exe %s, got %s", exe, got)
+	}
+	if got, _ := p.Cmdline(); !strings.Contains(got, os.Args[0]) {
+		t.Errorf("Expected Cmdline to contain %s, got %s", os.Args[0], got)
+	}
+	if got, _ := p.Username(); got == "" {
+		t.Errorf("Expected Username to be set")
+	}
 }
 
 func TestProcessImpl_Kill(t *testing.T) {
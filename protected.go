@@ -0,0 +1,55 @@
+package sleego
+
+import "os"
+
+// Process names that are never stopped, whatever the config says. They keep
+// the system, the session and remote access alive.
+var defaultProtectedNames = []string{
+	// Linux
+	"systemd", "init", "kthreadd", "dbus-daemon", "dbus-broker", "sshd", "login", "agetty",
+	"Xorg", "Xwayland", "gdm", "gdm3", "sddm", "lightdm", "gnome-shell", "kwin_x11", "kwin_wayland", "plasmashell",
+	"polkitd", "NetworkManager", "pipewire", "wireplumber", "pulseaudio",
+	// macOS
+	"launchd", "kernel_task", "WindowServer", "loginwindow",
+	// Windows
+	"System", "Registry", "smss.exe", "csrss.exe", "wininit.exe", "winlogon.exe", "services.exe",
+	"lsass.exe", "svchost.exe", "dwm.exe", "explorer.exe",
+}
+
+// PIDs of the kernel scheduler and init
+var defaultProtectedPids = []int{0, 1}
+
+// ProtectedConfig lists processes that must never be stopped, in addition to
+// the built-in ones, sleego itself and the process that started it
+type ProtectedConfig struct {
+	Names []string `json:"names,omitempty"`
+	Pids  []int    `json:"pids,omitempty"`
+}
+
+// protectedSet is the resolved allowlist of processes that are never stopped
+type protectedSet struct {
+	names map[string]bool
+	pids  map[int]bool
+}
+
+func newProtectedSet(cfg ProtectedConfig) protectedSet {
+	set := protectedSet{names: make(map[string]bool), pids: make(map[int]bool)}
+	for _, name := range protectedNames(cfg) {
+		set.names[name] = true
+	}
+	for _, pid := range append(append([]int(nil), defaultProtectedPids...), cfg.Pids...) {
+		set.pids[pid] = true
+	}
+	set.pids[os.Getpid()] = true
+	set.pids[os.Getppid()] = true
+	return set
+}
+
+// protectedNames returns the built-in protected names followed by the configured ones
+func protectedNames(cfg ProtectedConfig) []string {
+	return append(append([]string(nil), defaultProtectedNames...), cfg.Names...)
+}
+
+func (s protectedSet) contains(info ProcessInfo) bool {
+	return s.pids[info.Pid] || s.names[info.Name]
+}
@@ -0,0 +1,32 @@
+package sleego
+
+import (
+	"os"
+	"testing"
+)
+
+func TestProtectedSet_Contains(t *testing.T) {
+	set := newProtectedSet(ProtectedConfig{Names: []string{"backup-agent"}, Pids: []int{4242}})
+
+	tests := []struct {
+		name string
+		info ProcessInfo
+		want bool
+	}{
+		{name: "built-in name", info: ProcessInfo{Name: "sshd", Pid: 5000}, want: true},
+		{name: "configured name", info: ProcessInfo{Name: "backup-agent", Pid: 5001}, want: true},
+		{name: "configured pid", info: ProcessInfo{Name: "anything", Pid: 4242}, want: true},
+		{name: "init", info: ProcessInfo{Name: "whatever", Pid: 1}, want: true},
+		{name: "sleego itself", info: ProcessInfo{Name: "sleego", Pid: os.Getpid()}, want: true},
+		{name: "parent of sleego", info: ProcessInfo{Name: "bash", Pid: os.Getppid()}, want: true},
+		{name: "regular process", info: ProcessInfo{Name: "game.exe", Pid: 5002}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := set.contains(tt.info); got != tt.want {
+				t.Errorf("contains(%v) = %v, want %v", tt.info, got, tt.want)
+			}
+		})
+	}
+}
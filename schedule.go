@@ -0,0 +1,163 @@
+package sleego
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily interval in which an app is allowed to run
+type TimeWindow struct {
+	AllowedFrom string `json:"allowed_from"`
+	AllowedTo   string `json:"allowed_to"`
+}
+
+// TimeWindows is a list of windows that are OR-ed together. In JSON it may be
+// written either as a single window object or as an array of windows.
+type TimeWindows []TimeWindow
+
+func (w *TimeWindows) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var window TimeWindow
+		if err := json.Unmarshal(trimmed, &window); err != nil {
+			return err
+		}
+		*w = TimeWindows{window}
+		return nil
+	}
+	var windows []TimeWindow
+	if err := json.Unmarshal(data, &windows); err != nil {
+		return err
+	}
+	*w = windows
+	return nil
+}
+
+var weekdaysByName = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// hasWindows reports whether the app restricts the time of day it may run
+func (a AppConfig) hasWindows() bool {
+	return a.AllowedFrom != "" || a.AllowedTo != "" || len(a.Windows) != 0 || len(a.Schedule) != 0
+}
+
+// defaultWindows returns the windows used on days that are not in the schedule:
+// the AllowedFrom/AllowedTo pair, if any, followed by Windows.
+func (a AppConfig) defaultWindows() []TimeWindow {
+	windows := make([]TimeWindow, 0, len(a.Windows)+1)
+	if a.AllowedFrom != "" || a.AllowedTo != "" {
+		windows = append(windows, TimeWindow{AllowedFrom: a.AllowedFrom, AllowedTo: a.AllowedTo})
+	}
+	return append(windows, a.Windows...)
+}
+
+// windowsOn returns the windows that start on the given weekday. Weekdays
+// listed in the schedule use their own windows, every other day falls back to
+// the default windows.
+func (a AppConfig) windowsOn(day time.Weekday) []TimeWindow {
+	for key, windows := range a.Schedule {
+		days, err := parseWeekdays(key)
+		if err != nil {
+			continue
+		}
+		if days[day] {
+			return windows
+		}
+	}
+	return a.defaultWindows()
+}
+
+// windowBounds resolves a window starting on the given day into absolute
+// times. A window whose end is before its start runs past midnight, so its end
+// falls on the following day.
+func windowBounds(window TimeWindow, day time.Time) (time.Time, time.Time, error) {
+	from, err := time.Parse(configTimeLayout, window.AllowedFrom)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("error parsing allowedFrom: %w", err)
+	}
+	to, err := time.Parse(configTimeLayout, window.AllowedTo)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("error parsing allowedTo: %w", err)
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), from.Hour(), from.Minute(), 0, 0, day.Location())
+	end := time.Date(day.Year(), day.Month(), day.Day(), to.Hour(), to.Minute(), 0, 0, day.Location())
+	if end.Before(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, nil
+}
+
+// windowEnd returns when the app stops being allowed by its windows, given
+// that it is allowed at now. Windows that touch or overlap are treated as one,
+// so the result is the end of the whole contiguous allowed period.
+func (a AppConfig) windowEnd(now time.Time) (time.Time, bool) {
+	type interval struct{ start, end time.Time }
+	var intervals []interval
+	for offset := -1; offset <= 7; offset++ {
+		day := now.AddDate(0, 0, offset)
+		for _, window := range a.windowsOn(day.Weekday()) {
+			start, end, err := windowBounds(window, day)
+			if err != nil {
+				continue
+			}
+			intervals = append(intervals, interval{start: start, end: end})
+		}
+	}
+
+	end, found := now, false
+	for {
+		extended := false
+		for _, iv := range intervals {
+			// The interval has started by the current end and reaches further
+			if !iv.start.After(end) && !iv.end.Before(end) && (iv.end.After(end) || !found) {
+				end, found, extended = iv.end, true, true
+			}
+		}
+		if !extended {
+			return end, found
+		}
+	}
+}
+
+// parseWeekdays parses a schedule key such as "mon-fri", "sat,sun" or
+// "fri-mon" into the set of weekdays it covers.
+func parseWeekdays(key string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(key, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			return nil, fmt.Errorf("invalid weekday list %q", key)
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekdaysByName[strings.TrimSpace(from)]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q in %q", from, key)
+		}
+		last := first
+		if isRange {
+			last, ok = weekdaysByName[strings.TrimSpace(to)]
+			if !ok {
+				return nil, fmt.Errorf("unknown weekday %q in %q", to, key)
+			}
+		}
+
+		// Ranges may wrap around the end of the week, e.g. "fri-mon"
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
@@ -0,0 +1,154 @@
+package sleego
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    []time.Weekday
+		wantErr bool
+	}{
+		{key: "mon", want: []time.Weekday{time.Monday}},
+		{key: "mon-fri", want: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}},
+		{key: "sat,sun", want: []time.Weekday{time.Saturday, time.Sunday}},
+		{key: "Fri-Mon", want: []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday}},
+		{key: "mon, wed-thu", want: []time.Weekday{time.Monday, time.Wednesday, time.Thursday}},
+		{key: "", wantErr: true},
+		{key: "monday", wantErr: true},
+		{key: "mon-", wantErr: true},
+		{key: "mon,,tue", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := parseWeekdays(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWeekdays(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseWeekdays(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+			for _, day := range tt.want {
+				if !got[day] {
+					t.Errorf("parseWeekdays(%q) is missing %s", tt.key, day)
+				}
+			}
+		})
+	}
+}
+
+func TestAppConfigWindowsOn(t *testing.T) {
+	app := AppConfig{
+		AllowedFrom: "07:00",
+		AllowedTo:   "08:00",
+		Windows: []TimeWindow{
+			{AllowedFrom: "18:00", AllowedTo: "21:00"},
+		},
+		Schedule: map[string]TimeWindows{
+			"sat,sun": {{AllowedFrom: "10:00", AllowedTo: "22:00"}},
+		},
+	}
+
+	weekend := app.windowsOn(time.Saturday)
+	if len(weekend) != 1 || weekend[0].AllowedFrom != "10:00" || weekend[0].AllowedTo != "22:00" {
+		t.Errorf("windowsOn(Saturday) = %v, want the weekend window", weekend)
+	}
+
+	weekday := app.windowsOn(time.Monday)
+	if len(weekday) != 2 || weekday[0].AllowedFrom != "07:00" || weekday[1].AllowedFrom != "18:00" {
+		t.Errorf("windowsOn(Monday) = %v, want the default windows", weekday)
+	}
+
+	app.AllowedFrom, app.AllowedTo, app.Windows = "", "", nil
+	if got := app.windowsOn(time.Monday); len(got) != 0 {
+		t.Errorf("windowsOn(Monday) without defaults = %v, want no windows", got)
+	}
+}
+
+func TestWindowBounds_Overnight(t *testing.T) {
+	day := time.Date(2023, 10, 13, 0, 0, 0, 0, time.UTC)
+	start, end, err := windowBounds(TimeWindow{AllowedFrom: "22:00", AllowedTo: "02:00"}, day)
+	if err != nil {
+		t.Fatalf("windowBounds() error = %v", err)
+	}
+	if want := time.Date(2023, 10, 13, 22, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("start = %v, want %v", start, want)
+	}
+	if want := time.Date(2023, 10, 14, 2, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Errorf("end = %v, want %v", end, want)
+	}
+}
+
+func TestTimeWindows_UnmarshalJSON(t *testing.T) {
+	var app AppConfig
+	data := `{"name": "games", "schedule": {
+		"mon-fri": {"allowed_from": "18:00", "allowed_to": "20:00"},
+		"sat,sun": [{"allowed_from": "09:00", "allowed_to": "12:00"}, {"allowed_from": "14:00", "allowed_to": "22:00"}]
+	}}`
+	if err := json.Unmarshal([]byte(data), &app); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if got := app.Schedule["mon-fri"]; len(got) != 1 || got[0].AllowedFrom != "18:00" {
+		t.Errorf("single window object decoded as %v", got)
+	}
+	if got := app.Schedule["sat,sun"]; len(got) != 2 || got[1].AllowedTo != "22:00" {
+		t.Errorf("window array decoded as %v", got)
+	}
+}
+
+func TestAppConfigWindowEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		app      AppConfig
+		now      time.Time
+		want     time.Time
+		notFound bool
+	}{
+		{
+			name: "single window",
+			app:  AppConfig{AllowedFrom: "09:00", AllowedTo: "17:00"},
+			now:  time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC),
+			want: time.Date(2023, 10, 10, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "overnight window started yesterday",
+			app:  AppConfig{AllowedFrom: "22:00", AllowedTo: "02:00"},
+			now:  time.Date(2023, 10, 10, 1, 0, 0, 0, time.UTC),
+			want: time.Date(2023, 10, 10, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "adjacent windows are contiguous",
+			app: AppConfig{Windows: []TimeWindow{
+				{AllowedFrom: "07:00", AllowedTo: "08:00"},
+				{AllowedFrom: "08:00", AllowedTo: "09:30"},
+			}},
+			now:  time.Date(2023, 10, 10, 7, 30, 0, 0, time.UTC),
+			want: time.Date(2023, 10, 10, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "outside every window",
+			app:      AppConfig{AllowedFrom: "09:00", AllowedTo: "17:00"},
+			now:      time.Date(2023, 10, 10, 18, 0, 0, 0, time.UTC),
+			notFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.app.windowEnd(tt.now)
+			if ok == tt.notFound {
+				t.Fatalf("windowEnd() found = %v, want %v", ok, !tt.notFound)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Errorf("windowEnd() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
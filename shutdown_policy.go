@@ -4,10 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
-	"runtime"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/joaogabriel01/sleego/clock"
 	"github.com/joaogabriel01/sleego/internal/logger"
 )
 
@@ -16,85 +17,467 @@ type ShutdownPolicy interface {
 	Apply(ctx context.Context, endTime time.Time) error
 }
 
+// ShutdownPostponer is implemented by shutdown policies whose pending
+// shutdown can be delayed while Apply runs
+type ShutdownPostponer interface {
+	// Postpone delays the pending shutdown by d and returns its new time
+	Postpone(d time.Duration) (time.Time, error)
+	// Scheduled returns the time of the pending shutdown, false when none is pending
+	Scheduled() (time.Time, bool)
+}
+
+// ShutdownSnoozer is implemented by shutdown policies whose pending shutdown
+// can be snoozed a limited number of times, e.g. from a "10 more minutes" button
+type ShutdownSnoozer interface {
+	// SetSnooze configures the snooze, which is disabled when cfg.Count is zero
+	SetSnooze(cfg SnoozeConfig) error
+	// Snooze delays the pending shutdown by one step and returns its new time
+	Snooze() (time.Time, error)
+	// SnoozesLeft returns how many more times the pending shutdown can be snoozed
+	SnoozesLeft() int
+}
+
+// ShutdownWarner is implemented by shutdown policies that warn a number of
+// minutes ahead of the shutdown
+type ShutdownWarner interface {
+	// SetShutdownWarnings sets the minutes before the shutdown at which a
+	// warning is sent, from the next shutdown scheduled
+	SetShutdownWarnings(minutes []int)
+}
+
+// SnoozeConfig bounds how far the shutdown can be snoozed
+type SnoozeConfig struct {
+	// Count is how many times each shutdown can be snoozed
+	Count int `json:"count,omitempty"`
+	// Minutes is how long each snooze delays the shutdown
+	Minutes int `json:"minutes,omitempty"`
+	// Latest is the time of day (HH:MM) past which a shutdown is never snoozed
+	Latest string `json:"latest,omitempty"`
+}
+
+// ErrNoSnoozesLeft is returned by Snooze once the shutdown can't be delayed any further
+var ErrNoSnoozesLeft = errors.New("no snoozes left")
+
 type ShutdownPolicyImpl struct {
-	shutdown     func() error
-	c            chan string
-	timesToAlert []int
-	logger       logger.Logger
+	shutdown    func() error       // takes the power action, replaced in tests
+	runner      PowerCommandRunner // runs the power action commands
+	events      *EventBus
+	logger      logger.Logger
+	dryRun      bool
+	clock       clock.Clock
+	notifyDelay time.Duration // time notifiers get to deliver the shutdown event
+
+	mu            sync.Mutex
+	run           int       // counts the calls to Apply, to tell which one is pending
+	pendingRun    int       // call of Apply waiting for the pending shutdown
+	base          time.Time // time the pending shutdown was scheduled at by the config
+	scheduled     time.Time // time of the pending shutdown, zero when none is pending
+	postponedFrom time.Time // base of the last postponed shutdown
+	postponedTo   time.Time // time it was postponed to
+	reschedule    chan struct{}
+	snooze        SnoozeConfig
+	latest        time.Time // Latest of snooze parsed, zero when unset
+	snoozeBase    time.Time // base of the shutdown snoozed is counted for
+	snoozed       int
+	curfew        *curfew // nil when no curfew is configured
+	action        string  // power action, powering off when empty
+	timesToAlert  []int   // minutes before the shutdown at which a warning is sent
 }
 
-func NewShutdownPolicyImpl(c chan string, timesToAlert []int) ShutdownPolicy {
+// ShutdownPolicyOption configures optional behavior of a ShutdownPolicyImpl
+type ShutdownPolicyOption func(*ShutdownPolicyImpl)
+
+// WithShutdownDryRun makes the policy log the shutdown instead of executing it
+func WithShutdownDryRun() ShutdownPolicyOption {
+	return func(s *ShutdownPolicyImpl) {
+		s.dryRun = true
+	}
+}
+
+// WithShutdownClock replaces the real clock, e.g. with a fake one in tests
+func WithShutdownClock(c clock.Clock) ShutdownPolicyOption {
+	return func(s *ShutdownPolicyImpl) {
+		s.clock = c
+	}
+}
+
+// NewShutdownPolicyImpl creates a ShutdownPolicyImpl that warns the given
+// numbers of minutes ahead. Events are published on events, which may be nil.
+func NewShutdownPolicyImpl(events *EventBus, timesToAlert []int, opts ...ShutdownPolicyOption) ShutdownPolicy {
 	logger, err := logger.Get()
 	if err != nil {
 		panic(fmt.Sprintf("failed to get logger: %v", err))
 	}
 
-	return &ShutdownPolicyImpl{
-		shutdown:     shutdown,
-		c:            c,
+	s := &ShutdownPolicyImpl{
+		runner:       runPowerCommand,
+		events:       events,
 		timesToAlert: timesToAlert,
 		logger:       logger,
+		clock:        clock.Real(),
+		notifyDelay:  shutdownNotifyDelay,
+		reschedule:   make(chan struct{}, 1),
+	}
+	s.shutdown = s.runPowerAction
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-// Apply schedules a shutdown at the specified time.
+// Apply schedules a shutdown at the specified time, or after the grace
+// period when it is called during the curfew. The shutdown can be postponed
+// while it is pending. Apply returns once the machine is powered off or
+// rebooted; after the other power actions, in dry run or when the action
+// fails, it schedules the next shutdown.
 func (s *ShutdownPolicyImpl) Apply(ctx context.Context, endTime time.Time) error {
-	now := time.Now()
-	shutdownTime := time.Date(now.Year(), now.Month(), now.Day(), endTime.Hour(), endTime.Minute(), endTime.Second(), 0, now.Location())
-
-	if shutdownTime.Before(now) {
-		shutdownTime = shutdownTime.Add(24 * time.Hour)
+	var run int
+	var curfewEnd time.Time // end of the curfew the pending shutdown enforces, if any
+	defer func() { s.unschedule(run) }()
+	// schedule schedules the next shutdown from now, but not before after
+	schedule := func(after time.Time) time.Time {
+		now := s.clock.Now()
+		if now.Before(after) {
+			now = after
+		}
+		var shutdownTime time.Time
+		run, shutdownTime, curfewEnd = s.schedule(now, endTime)
+		if !curfewEnd.IsZero() {
+			minutes := int(shutdownTime.Sub(now).Round(time.Minute) / time.Minute)
+			msg := fmt.Sprintf("Curfew until %s, %s in %d minutes", curfewEnd.Format("15:04"), strings.ToLower(s.powerAction().verb), minutes)
+			s.logger.Info(msg)
+			s.events.Publish(Event{Kind: EventShutdownWarning, Time: now, Scheduled: shutdownTime, MinutesRemaining: minutes, Message: msg})
+		}
+		return shutdownTime
 	}
 
-	duration := time.Until(shutdownTime)
-	if duration <= 0 {
-		s.logger.Debug("Shutting down now")
-		return s.shutdown()
+	shutdownTime := schedule(time.Time{})
+	for {
+		if duration := shutdownTime.Sub(s.clock.Now()); duration > 0 {
+			s.logger.Info(fmt.Sprintf("%s scheduled in %v", s.powerAction().verb, duration))
+			alertCtx, cancelAlerts := context.WithCancel(ctx)
+			s.alert(alertCtx, shutdownTime, duration)
+			due, err := s.wait(ctx, shutdownTime)
+			cancelAlerts()
+			if err != nil {
+				return err
+			}
+			if !due {
+				shutdownTime, _ = s.Scheduled()
+				continue
+			}
+			if now := s.clock.Now(); now.Sub(shutdownTime) > wallClockCheck {
+				// The machine slept through the shutdown. A curfew shutdown
+				// is dropped once its curfew is over, any other still happens.
+				if !curfewEnd.IsZero() && !now.Before(curfewEnd) {
+					shutdownTime = schedule(time.Time{})
+				} else {
+					shutdownTime = s.missed(now, shutdownTime)
+				}
+				continue
+			}
+		}
+
+		action := s.powerAction()
+		err := s.shutdownNow(ctx, shutdownTime, action)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("%s failed, trying again the next day: %v", action.verb, err))
+		} else if !action.keepsRunning && !s.dryRun {
+			return nil
+		}
+		// The action may return within the second it was taken at
+		shutdownTime = schedule(shutdownTime.Add(time.Second))
 	}
+}
+
+// Time left before a shutdown the machine slept through happens after it wakes up
+const missedShutdownGrace = 2 * time.Minute
 
-	s.logger.Info(fmt.Sprintf("Shutting down scheduled in %v", duration))
+// missed moves a shutdown the machine slept through to shortly after now and warns about it
+func (s *ShutdownPolicyImpl) missed(now, missed time.Time) time.Time {
+	s.mu.Lock()
+	scheduled := s.moveLocked(now.Add(missedShutdownGrace))
+	s.mu.Unlock()
 
-	timer := time.NewTimer(duration)
-	defer timer.Stop()
+	minutes := int(missedShutdownGrace / time.Minute)
+	msg := fmt.Sprintf("Missed the %s shutdown while asleep, %s in %d minutes", missed.Format("15:04"), strings.ToLower(s.powerAction().verb), minutes)
+	s.logger.Info(msg)
+	s.events.Publish(Event{Kind: EventShutdownWarning, Time: now, Scheduled: scheduled, MinutesRemaining: minutes, Message: msg})
+	return scheduled
+}
+
+// How often the wall clock is checked while a shutdown is pending. Timers
+// stop while the machine is suspended, so the wall clock is what tells when
+// the shutdown is due after it resumes.
+const wallClockCheck = time.Minute
+
+// wait blocks until the wall clock reaches t, reporting false when the
+// pending shutdown is rescheduled first
+func (s *ShutdownPolicyImpl) wait(ctx context.Context, t time.Time) (bool, error) {
+	for {
+		duration := t.Sub(s.clock.Now())
+		if duration <= 0 {
+			return true, nil
+		}
+		timer := s.clock.NewTimer(min(duration, wallClockCheck))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		case <-timer.C():
+		case <-s.reschedule:
+			timer.Stop()
+			return false, nil
+		}
+	}
+}
 
-	for _, timeToAlert := range s.timesToAlert {
+// alert sends the warnings before a shutdown in duration, until ctx is done
+func (s *ShutdownPolicyImpl) alert(ctx context.Context, shutdownTime time.Time, duration time.Duration) {
+	s.mu.Lock()
+	timesToAlert := s.timesToAlert
+	s.mu.Unlock()
+	for _, timeToAlert := range timesToAlert {
 		alertDuration := duration - time.Duration(timeToAlert)*time.Minute
 		if alertDuration > 0 {
 			timeToAlert := timeToAlert
 			go func() {
 				select {
 				case <-ctx.Done():
-				case <-time.After(alertDuration):
-					msg := fmt.Sprintf("Shutting down in %d minutes", timeToAlert)
+				case <-s.clock.After(alertDuration):
+					msg := fmt.Sprintf("%s in %d minutes", s.powerAction().verb, timeToAlert)
 					s.logger.Debug(msg)
-					s.c <- msg
+					s.events.Publish(Event{Kind: EventShutdownWarning, Time: s.clock.Now(), Scheduled: shutdownTime, MinutesRemaining: timeToAlert, Message: msg})
 				}
 			}()
 		}
 	}
+}
+
+// SetShutdownWarnings sets the minutes before the shutdown at which a
+// warning is sent, taking effect from the next shutdown scheduled
+func (s *ShutdownPolicyImpl) SetShutdownWarnings(minutes []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timesToAlert = minutes
+}
 
+var _ ShutdownWarner = &ShutdownPolicyImpl{}
+
+// schedule makes the next shutdown at endTime the pending one. During the
+// curfew the shutdown is brought forward to the end of the grace period, and
+// the end of the curfew is returned as well. A shutdown that was postponed
+// stays postponed, even past its time of day, so restarting Apply doesn't
+// bring it forward or skip it.
+func (s *ShutdownPolicyImpl) schedule(now, endTime time.Time) (int, time.Time, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.run++
+	s.pendingRun = s.run
+	s.base = nextShutdownTime(now, endTime)
+	s.scheduled = s.base
+	bases := []time.Time{s.base, s.base.Add(-24 * time.Hour)}
+
+	var curfewEnd time.Time
+	if start, end, ok := s.curfew.period(now); ok {
+		// A curfew shutdown is based on the start of the curfew, so that a
+		// postponement of it is found again below
+		bases = append(bases, start)
+		if at := now.Add(s.curfew.grace); at.Before(s.scheduled) {
+			s.base, s.scheduled, curfewEnd = start, at, end
+		}
+	}
+	if now.Before(s.postponedTo) {
+		for _, base := range bases {
+			if s.postponedFrom.Equal(base) {
+				s.base, s.scheduled, curfewEnd = base, s.postponedTo, time.Time{}
+			}
+		}
+	}
+	// Drop a postponement of a previous call
 	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-timer.C:
-		s.logger.Debug("Shutting down now")
-		return s.shutdown()
+	case <-s.reschedule:
+	default:
 	}
+	return s.run, s.scheduled, curfewEnd
 }
 
-var _ ShutdownPolicy = &ShutdownPolicyImpl{}
+// unschedule clears the pending shutdown, unless a later call of Apply
+// already scheduled another one
+func (s *ShutdownPolicyImpl) unschedule(run int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pendingRun == run {
+		s.scheduled = time.Time{}
+	}
+}
+
+// Scheduled returns the time of the pending shutdown, false when none is pending
+func (s *ShutdownPolicyImpl) Scheduled() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scheduled, !s.scheduled.IsZero()
+}
+
+// Postpone delays the pending shutdown by d, on top of earlier postponements
+// and regardless of the snooze limits
+func (s *ShutdownPolicyImpl) Postpone(d time.Duration) (time.Time, error) {
+	if d <= 0 || d > maxOverride {
+		return time.Time{}, fmt.Errorf("invalid postponement %v, must be positive and at most %v", d, maxOverride)
+	}
+	s.mu.Lock()
+	if s.scheduled.IsZero() {
+		s.mu.Unlock()
+		return time.Time{}, errNoShutdownScheduled
+	}
+	scheduled := s.moveLocked(s.scheduled.Add(d))
+	s.mu.Unlock()
+
+	s.rescheduled(scheduled, "Shutdown postponed to "+scheduled.Format("15:04"))
+	return scheduled, nil
+}
+
+var errNoShutdownScheduled = errors.New("no shutdown is scheduled")
+
+// SetSnooze configures the snooze of the shutdowns scheduled from now on and
+// of the pending one
+func (s *ShutdownPolicyImpl) SetSnooze(cfg SnoozeConfig) error {
+	var latest time.Time
+	if cfg.Latest != "" {
+		var err error
+		if latest, err = time.Parse(configTimeLayout, cfg.Latest); err != nil {
+			return fmt.Errorf("error parsing latest snooze time: %w", err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snooze, s.latest = cfg, latest
+	return nil
+}
 
-func shutdown() error {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "windows":
-		cmd = exec.Command("shutdown", "/s", "/f", "/t", "0")
-	case "linux":
-		cmd = exec.Command("shutdown", "-h", "now")
-	case "darwin":
-		cmd = exec.Command("sudo", "shutdown", "-h", "now")
+// Snooze delays the pending shutdown by the configured number of minutes.
+// Each shutdown can be snoozed the configured number of times, and never
+// past the latest time; the last snooze may be shorter to end there.
+func (s *ShutdownPolicyImpl) Snooze() (time.Time, error) {
+	s.mu.Lock()
+	if s.snooze.Count <= 0 || s.snooze.Minutes <= 0 {
+		s.mu.Unlock()
+		return time.Time{}, errors.New("snoozing is not configured")
+	}
+	if s.scheduled.IsZero() {
+		s.mu.Unlock()
+		return time.Time{}, errNoShutdownScheduled
+	}
+	if s.snoozesLeftLocked() == 0 {
+		s.mu.Unlock()
+		return time.Time{}, ErrNoSnoozesLeft
+	}
+	next := s.scheduled.Add(time.Duration(s.snooze.Minutes) * time.Minute)
+	if latest, ok := s.latestLocked(); ok && next.After(latest) {
+		next = latest
+	}
+	s.snoozed++
+	scheduled := s.moveLocked(next)
+	left := s.snoozesLeftLocked()
+	s.mu.Unlock()
+
+	s.rescheduled(scheduled, fmt.Sprintf("Shutdown snoozed to %s, %d snoozes left", scheduled.Format("15:04"), left))
+	return scheduled, nil
+}
+
+// SnoozesLeft returns how many more times the pending shutdown can be snoozed
+func (s *ShutdownPolicyImpl) SnoozesLeft() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scheduled.IsZero() || s.snooze.Minutes <= 0 {
+		return 0
+	}
+	return s.snoozesLeftLocked()
+}
+
+func (s *ShutdownPolicyImpl) snoozesLeftLocked() int {
+	// The count starts over for every shutdown
+	if !s.snoozeBase.Equal(s.base) {
+		s.snoozeBase, s.snoozed = s.base, 0
+	}
+	if latest, ok := s.latestLocked(); ok && !s.scheduled.Before(latest) {
+		return 0
+	}
+	// Snoozing is meant to finish something before the curfew, not to stay up in it
+	if _, _, ok := s.curfew.period(s.clock.Now()); ok {
+		return 0
+	}
+	return max(s.snooze.Count-s.snoozed, 0)
+}
+
+// latestLocked returns the latest time the pending shutdown may be snoozed
+// to: the first latest time of day after the time it was scheduled at
+func (s *ShutdownPolicyImpl) latestLocked() (time.Time, bool) {
+	if s.snooze.Latest == "" {
+		return time.Time{}, false
+	}
+	return nextShutdownTime(s.base, s.latest), true
+}
+
+// moveLocked moves the pending shutdown to t, remembering it in case Apply restarts
+func (s *ShutdownPolicyImpl) moveLocked(t time.Time) time.Time {
+	s.scheduled = t
+	s.postponedFrom, s.postponedTo = s.base, t
+	return t
+}
+
+// rescheduled makes Apply wait for the moved shutdown and reports it
+func (s *ShutdownPolicyImpl) rescheduled(scheduled time.Time, msg string) {
+	select {
+	case s.reschedule <- struct{}{}:
 	default:
-		return errors.New("unsupported operating system")
 	}
-	return cmd.Run()
+	s.logger.Info(msg)
+	s.events.Publish(Event{Kind: EventShutdownPostponed, Time: s.clock.Now(), Scheduled: scheduled, Message: msg})
+}
+
+// nextShutdownTime returns the first time of day of endTime that is not before now
+func nextShutdownTime(now, endTime time.Time) time.Time {
+	shutdownTime := time.Date(now.Year(), now.Month(), now.Day(), endTime.Hour(), endTime.Minute(), endTime.Second(), 0, now.Location())
+	if shutdownTime.Before(now) {
+		shutdownTime = shutdownTime.Add(24 * time.Hour)
+	}
+	return shutdownTime
+}
+
+// Time notifiers get to deliver the shutdown event before the power action is taken
+const shutdownNotifyDelay = 3 * time.Second
+
+// shutdownNow announces the power action and takes it, reporting a failure
+// in a separate event
+func (s *ShutdownPolicyImpl) shutdownNow(ctx context.Context, scheduled time.Time, action powerAction) error {
+	event := Event{Kind: EventShutdown, Time: s.clock.Now(), Scheduled: scheduled, Action: action.name, Message: action.verb + " now"}
+	if s.dryRun {
+		event.DryRun, event.Message = true, "Dry run, would "+action.infinitive+" now"
+		s.logger.Info(event.Message)
+		s.events.Publish(event)
+		return nil
+	}
+	s.logger.Debug(event.Message)
+	s.events.Publish(event)
+	if s.notifyDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(s.notifyDelay):
+		}
+	}
+	if err := s.shutdown(); err != nil {
+		msg := fmt.Sprintf("Failed to %s: %v", action.infinitive, err)
+		s.events.Publish(Event{Kind: EventShutdownFailed, Time: s.clock.Now(), Scheduled: scheduled, Action: action.name, Error: err.Error(), Message: msg})
+		return err
+	}
+	return nil
 }
+
+var _ ShutdownPolicy = &ShutdownPolicyImpl{}
+var _ ShutdownPostponer = &ShutdownPolicyImpl{}
+var _ ShutdownSnoozer = &ShutdownPolicyImpl{}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/joaogabriel01/sleego/clock/fake"
 	"github.com/joaogabriel01/sleego/internal/logger"
 )
 
@@ -20,125 +21,467 @@ func (m *MockShutdown) Shutdown() error {
 
 var ctxOk = context.Background()
 
+var shutdownTestNow = time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+
+// applyAsync runs Apply in the background and returns its result on a channel
+func applyAsync(ctx context.Context, policy *ShutdownPolicyImpl, endTime time.Time) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- policy.Apply(ctx, endTime)
+	}()
+	return done
+}
+
 func TestShutdownPolicyImpl_Apply_ShutdownCalled(t *testing.T) {
 	mockShutdown := &MockShutdown{}
-	alertCh := make(chan string, 1)
+	events, alertCh := newTestBus(1)
 	timesToAlert := []int{1}
+	clock := fake.NewClock(shutdownTestNow)
 
 	policy := &ShutdownPolicyImpl{
 		shutdown: func() error {
 			return mockShutdown.Shutdown()
 		},
-		c:            alertCh,
+		events:       events,
 		timesToAlert: timesToAlert,
 		logger:       logger.NewLoggerMock(),
+		clock:        clock,
 	}
 
-	endTime := time.Now().Add(3 * time.Second)
-	endTimeStr := endTime.Format("15:04:05")
-	t.Log(endTimeStr)
-	endTimeParsed, _ := time.Parse("15:04:05", endTimeStr)
-	go func() {
-		err := policy.Apply(ctxOk, endTimeParsed)
-		if err != nil {
-			t.Errorf("Apply returned error: %v", err)
-		}
-	}()
-
-	time.Sleep(5 * time.Second)
+	done := applyAsync(ctxOk, policy, shutdownTestNow.Add(3*time.Second))
+	clock.BlockUntil(1)
+	clock.Advance(3 * time.Second)
 
+	if err := <-done; err != nil {
+		t.Errorf("Apply returned error: %v", err)
+	}
 	if !mockShutdown.called {
 		t.Errorf("Expected shutdown to be called, but it was not")
 	}
+	if event := <-alertCh; event.Kind != EventShutdown || event.Error != "" || event.DryRun {
+		t.Errorf("Unexpected event: %+v", event)
+	}
 }
 
 func TestShutdownPolicyImpl_Apply_AlertSent(t *testing.T) {
 	mockShutdown := &MockShutdown{}
-	alertCh := make(chan string, 1)
+	events, alertCh := newTestBus(1)
 	timesToAlert := []int{1} // 1 minute before shutdown
+	clock := fake.NewClock(shutdownTestNow)
 
 	policy := &ShutdownPolicyImpl{
 		shutdown: func() error {
 			return mockShutdown.Shutdown()
 		},
-		c:            alertCh,
+		events:       events,
 		timesToAlert: timesToAlert,
 		logger:       logger.NewLoggerMock(),
+		clock:        clock,
 	}
 
 	// Set endTime to 2 minutes from now
-	endTime := time.Now().Add(2 * time.Minute)
-	go func() {
-		err := policy.Apply(ctxOk, endTime)
-		if err != nil {
-			t.Errorf("Apply returned error: %v", err)
-		}
-	}()
+	done := applyAsync(ctxOk, policy, shutdownTestNow.Add(2*time.Minute))
+	clock.BlockUntil(2)
+
+	clock.Advance(time.Minute - time.Second)
+	select {
+	case msg := <-alertCh:
+		t.Fatalf("Alert sent too early: %s", msg.Message)
+	default:
+	}
 
-	// Wait for alert
+	clock.Advance(time.Second)
 	select {
 	case msg := <-alertCh:
 		expectedMsg := "Shutting down in 1 minutes"
-		if msg != expectedMsg {
-			t.Errorf("Expected alert message '%s', got '%s'", expectedMsg, msg)
+		if msg.Message != expectedMsg {
+			t.Errorf("Expected alert message '%s', got '%s'", expectedMsg, msg.Message)
 		}
-	case <-time.After(3 * time.Minute):
-		t.Errorf("Did not receive expected alert message")
+		if msg.Kind != EventShutdownWarning || msg.MinutesRemaining != 1 || !msg.Scheduled.Equal(shutdownTestNow.Add(2*time.Minute)) {
+			t.Errorf("Unexpected warning event: %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Did not receive expected alert message")
+	}
+
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Errorf("Apply returned error: %v", err)
+	}
+	if !mockShutdown.called {
+		t.Errorf("Expected shutdown to be called after the alert")
 	}
 }
 
-func TestShutdownPolicyImpl_Apply_ShutdownError(t *testing.T) {
+func TestShutdownPolicyImpl_Apply_ShutdownTomorrow(t *testing.T) {
+	clock := fake.NewClock(shutdownTestNow)
 	mockShutdown := &MockShutdown{}
-	alertCh := make(chan string, 1)
-	timesToAlert := []int{1}
+	policy := &ShutdownPolicyImpl{
+		shutdown: func() error {
+			return mockShutdown.Shutdown()
+		},
+		logger: logger.NewLoggerMock(),
+		clock:  clock,
+	}
+
+	// 11:00 has passed today, so the shutdown happens tomorrow
+	done := applyAsync(ctxOk, policy, time.Date(0, 1, 1, 11, 0, 0, 0, time.UTC))
+	clock.BlockUntil(1)
+	clock.Advance(22*time.Hour + 59*time.Minute)
+	if mockShutdown.called {
+		t.Fatal("Shutdown called before the next day")
+	}
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Errorf("Apply returned error: %v", err)
+	}
+	if !mockShutdown.called {
+		t.Errorf("Expected shutdown to be called the next day")
+	}
+}
+
+func TestShutdownPolicyImpl_Apply_ShutdownError(t *testing.T) {
+	events, alertCh := newTestBus(2)
+	clock := fake.NewClock(shutdownTestNow)
 
 	policy := &ShutdownPolicyImpl{
 		shutdown: func() error {
 			return errors.New("shutdown failed")
 		},
-		c:            alertCh,
-		timesToAlert: timesToAlert,
-		logger:       logger.NewLoggerMock(),
+		events: events,
+		logger: logger.NewLoggerMock(),
+		clock:  clock,
 	}
 
-	endTime := time.Now().Add(2 * time.Second)
-	go func() {
-		err := policy.Apply(ctxOk, endTime)
-		if err == nil {
-			t.Errorf("Expected error from shutdown, but got none")
-		}
-	}()
+	ctx, cancel := context.WithCancel(ctxOk)
+	endTime := shutdownTestNow.Add(2 * time.Second)
+	done := applyAsync(ctx, policy, endTime)
+	clock.BlockUntil(1)
+	clock.Advance(2 * time.Second)
 
-	time.Sleep(3 * time.Second)
+	if event := <-alertCh; event.Kind != EventShutdown || event.Error != "" {
+		t.Errorf("Expected the shutdown event, got %+v", event)
+	}
+	if event := <-alertCh; event.Kind != EventShutdownFailed || event.Error != "shutdown failed" || event.Message != "Failed to shut down: shutdown failed" {
+		t.Errorf("Expected a failure event with the error, got %+v", event)
+	}
+	clock.BlockUntil(1)
+	if got, _ := policy.Scheduled(); !got.Equal(endTime.Add(24 * time.Hour)) {
+		t.Errorf("Expected the shutdown to be tried again the next day, got %v", got)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Apply to run until canceled, got %v", err)
+	}
+}
 
-	if mockShutdown.called {
-		t.Errorf("Shutdown should not have been called due to error")
+func TestShutdownPolicyImpl_AnnouncesShutdownFirst(t *testing.T) {
+	events, alertCh := newTestBus(1)
+	clock := fake.NewClock(shutdownTestNow)
+	shutdowns := make(chan time.Time, 1)
+	policy := &ShutdownPolicyImpl{
+		shutdown: func() error {
+			shutdowns <- clock.Now()
+			return nil
+		},
+		events:      events,
+		logger:      logger.NewLoggerMock(),
+		clock:       clock,
+		notifyDelay: shutdownNotifyDelay,
+	}
+
+	done := applyAsync(ctxOk, policy, shutdownTestNow.Add(time.Minute))
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	if event := <-alertCh; event.Kind != EventShutdown {
+		t.Errorf("Expected the shutdown event, got %+v", event)
+	}
+	// Notifiers get time to deliver the event before the machine goes down
+	clock.BlockUntil(1)
+	select {
+	case <-shutdowns:
+		t.Fatal("Shut down before notifiers could deliver the event")
+	default:
+	}
+	clock.Advance(shutdownNotifyDelay)
+	if err := <-done; err != nil {
+		t.Errorf("Apply returned error: %v", err)
+	}
+	if at := <-shutdowns; !at.Equal(shutdownTestNow.Add(time.Minute + shutdownNotifyDelay)) {
+		t.Errorf("Shut down at %v, want after the notify delay", at)
 	}
 }
 
 func TestShutdownPolicyImpl_ContextCancelled(t *testing.T) {
 	mockShutdown := &MockShutdown{}
-	alertCh := make(chan string, 1)
+	events := NewEventBus()
 	timesToAlert := []int{1}
 
 	policy := &ShutdownPolicyImpl{
 		shutdown: func() error {
 			return mockShutdown.Shutdown()
 		},
-		c:            alertCh,
+		events:       events,
 		timesToAlert: timesToAlert,
 		logger:       logger.NewLoggerMock(),
+		clock:        fake.NewClock(shutdownTestNow),
 	}
 	ctx, cancel := context.WithCancel(ctxOk)
 	cancel()
-	endTime := time.Now().Add(2 * time.Second)
-	go func() {
-		err := policy.Apply(ctx, endTime)
-		if err == nil {
-			t.Errorf("Expected error from context cancellation, but got none")
+
+	if err := <-applyAsync(ctx, policy, shutdownTestNow.Add(2*time.Second)); err == nil {
+		t.Errorf("Expected error from context cancellation, but got none")
+	}
+	if mockShutdown.called {
+		t.Errorf("Shutdown must not be called once the context is cancelled")
+	}
+}
+
+func TestShutdownPolicyImpl_DryRunDoesNotShutdown(t *testing.T) {
+	mockShutdown := &MockShutdown{}
+	clock := fake.NewClock(shutdownTestNow)
+
+	policy := &ShutdownPolicyImpl{
+		shutdown: func() error {
+			return mockShutdown.Shutdown()
+		},
+		logger: logger.NewLoggerMock(),
+		clock:  clock,
+	}
+	WithShutdownDryRun()(policy)
+
+	ctx, cancel := context.WithCancel(ctxOk)
+	endTime := shutdownTestNow.Add(time.Second)
+	done := applyAsync(ctx, policy, endTime)
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	// The machine keeps running, so the next day's shutdown is scheduled
+	clock.BlockUntil(1)
+	if got, _ := policy.Scheduled(); !got.Equal(endTime.Add(24 * time.Hour)) {
+		t.Errorf("Expected the next shutdown the following day, got %v", got)
+	}
+	if mockShutdown.called {
+		t.Errorf("Shutdown must not be called in dry run")
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Apply to run until canceled, got %v", err)
+	}
+}
+
+// newPostponableShutdownPolicy creates a policy that records its shutdowns
+// on the returned channel
+func newPostponableShutdownPolicy(clock *fake.Clock, events *EventBus, timesToAlert []int) (*ShutdownPolicyImpl, <-chan time.Time) {
+	shutdowns := make(chan time.Time, 1)
+	policy := &ShutdownPolicyImpl{
+		events:       events,
+		timesToAlert: timesToAlert,
+		logger:       logger.NewLoggerMock(),
+		clock:        clock,
+		reschedule:   make(chan struct{}, 1),
+	}
+	policy.shutdown = func() error {
+		shutdowns <- clock.Now()
+		return nil
+	}
+	return policy, shutdowns
+}
+
+func TestShutdownPolicyImpl_Postpone(t *testing.T) {
+	clock := fake.NewClock(shutdownTestNow)
+	events, ch := newTestBus(10)
+	policy, shutdowns := newPostponableShutdownPolicy(clock, events, []int{30})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := applyAsync(ctx, policy, shutdownTestNow.Add(2*time.Hour))
+	clock.BlockUntil(2)
+
+	scheduled, err := policy.Postpone(time.Hour)
+	if err != nil {
+		t.Fatalf("Postpone() error = %v", err)
+	}
+	if want := shutdownTestNow.Add(3 * time.Hour); !scheduled.Equal(want) {
+		t.Errorf("Postpone() = %v, want %v", scheduled, want)
+	}
+	if event := <-ch; event.Kind != EventShutdownPostponed || !event.Scheduled.Equal(scheduled) {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if got, ok := policy.Scheduled(); !ok || !got.Equal(scheduled) {
+		t.Errorf("Scheduled() = %v, %v, want %v", got, ok, scheduled)
+	}
+
+	// The timer and the warning of the new time, besides the stale warning
+	clock.BlockUntil(3)
+	clock.Advance(2 * time.Hour)
+	select {
+	case at := <-shutdowns:
+		t.Fatalf("Shut down at %v despite the postponement", at)
+	default:
+	}
+
+	clock.Advance(30 * time.Minute)
+	select {
+	case event := <-ch:
+		if event.Kind != EventShutdownWarning || !event.Scheduled.Equal(scheduled) {
+			t.Errorf("Expected the warning for the new time, got %+v", event)
 		}
-	}()
+	case <-time.After(time.Second):
+		t.Fatal("Expected a warning before the postponed shutdown")
+	}
+
+	clock.Advance(30 * time.Minute)
+	if err := <-done; err != nil {
+		t.Errorf("Apply returned error: %v", err)
+	}
+	if at := <-shutdowns; !at.Equal(scheduled) {
+		t.Errorf("Shut down at %v, want %v", at, scheduled)
+	}
+	if _, ok := policy.Scheduled(); ok {
+		t.Error("Expected no pending shutdown after it happened")
+	}
+}
+
+func TestShutdownPolicyImpl_PostponeWithoutPendingShutdown(t *testing.T) {
+	policy, _ := newPostponableShutdownPolicy(fake.NewClock(shutdownTestNow), nil, nil)
+	if _, err := policy.Postpone(time.Hour); err == nil {
+		t.Error("Expected Postpone() to fail without a pending shutdown")
+	}
+}
 
-	// Cancel context
-	time.Sleep(1 * time.Second)
+func TestShutdownPolicyImpl_PostponementSurvivesRestart(t *testing.T) {
+	clock := fake.NewClock(shutdownTestNow)
+	policy, shutdowns := newPostponableShutdownPolicy(clock, nil, nil)
+	endTime := shutdownTestNow.Add(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := applyAsync(ctx, policy, endTime)
+	clock.BlockUntil(1)
+	if _, err := policy.Postpone(time.Hour); err != nil {
+		t.Fatalf("Postpone() error = %v", err)
+	}
+
+	// Restarted, e.g. on a reload, after the shutdown's original time
+	clock.Advance(90 * time.Minute)
+	cancel()
+	<-done
+	done = applyAsync(context.Background(), policy, endTime)
+	clock.BlockUntil(1)
+	if got, _ := policy.Scheduled(); !got.Equal(shutdownTestNow.Add(2 * time.Hour)) {
+		t.Errorf("Expected the postponed shutdown to stay pending, got %v", got)
+	}
+
+	clock.Advance(30 * time.Minute)
+	if err := <-done; err != nil {
+		t.Errorf("Apply returned error: %v", err)
+	}
+	if at := <-shutdowns; !at.Equal(shutdownTestNow.Add(2 * time.Hour)) {
+		t.Errorf("Shut down at %v, want the postponed time", at)
+	}
+}
+
+func TestShutdownPolicyImpl_Snooze(t *testing.T) {
+	clock := fake.NewClock(shutdownTestNow)
+	events, ch := newTestBus(10)
+	policy, shutdowns := newPostponableShutdownPolicy(clock, events, nil)
+	endTime := shutdownTestNow.Add(time.Hour)
+	if err := policy.SetSnooze(SnoozeConfig{Count: 3, Minutes: 10, Latest: endTime.Add(25 * time.Minute).Format("15:04")}); err != nil {
+		t.Fatalf("SetSnooze() error = %v", err)
+	}
+
+	done := applyAsync(context.Background(), policy, endTime)
+	clock.BlockUntil(1)
+	if left := policy.SnoozesLeft(); left != 3 {
+		t.Errorf("SnoozesLeft() = %d, want 3", left)
+	}
+
+	steps := []struct {
+		want    time.Duration
+		left    int
+		message string
+	}{
+		{want: 70 * time.Minute, left: 2, message: "Shutdown snoozed to 13:10, 2 snoozes left"},
+		{want: 80 * time.Minute, left: 1, message: "Shutdown snoozed to 13:20, 1 snoozes left"},
+		// Shortened to end at the latest time, which leaves no snooze
+		{want: 85 * time.Minute, left: 0, message: "Shutdown snoozed to 13:25, 0 snoozes left"},
+	}
+	for i, step := range steps {
+		scheduled, err := policy.Snooze()
+		if err != nil {
+			t.Fatalf("Snooze %d: unexpected error %v", i, err)
+		}
+		if want := shutdownTestNow.Add(step.want); !scheduled.Equal(want) {
+			t.Errorf("Snooze %d: got %v, want %v", i, scheduled, want)
+		}
+		if left := policy.SnoozesLeft(); left != step.left {
+			t.Errorf("Snooze %d: SnoozesLeft() = %d, want %d", i, left, step.left)
+		}
+		if event := <-ch; event.Kind != EventShutdownPostponed || event.Message != step.message {
+			t.Errorf("Snooze %d: unexpected event %+v", i, event)
+		}
+	}
+	if _, err := policy.Snooze(); !errors.Is(err, ErrNoSnoozesLeft) {
+		t.Errorf("Expected ErrNoSnoozesLeft, got %v", err)
+	}
+
+	clock.Advance(85*time.Minute - time.Second)
+	select {
+	case at := <-shutdowns:
+		t.Fatalf("Shut down at %v before the snoozed time", at)
+	default:
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("Apply returned error: %v", err)
+	}
+	if at := <-shutdowns; !at.Equal(shutdownTestNow.Add(85 * time.Minute)) {
+		t.Errorf("Shut down at %v, want the snoozed time", at)
+	}
+}
+
+func TestShutdownPolicyImpl_SnoozeCountStartsOverEachShutdown(t *testing.T) {
+	policy, _ := newPostponableShutdownPolicy(fake.NewClock(shutdownTestNow), nil, nil)
+	policy.SetSnooze(SnoozeConfig{Count: 1, Minutes: 10})
+	endTime := shutdownTestNow.Add(time.Hour)
+
+	run, _, _ := policy.schedule(shutdownTestNow, endTime)
+	if _, err := policy.Snooze(); err != nil {
+		t.Fatalf("Snooze() error = %v", err)
+	}
+	if _, err := policy.Snooze(); !errors.Is(err, ErrNoSnoozesLeft) {
+		t.Errorf("Expected ErrNoSnoozesLeft, got %v", err)
+	}
+	policy.unschedule(run)
+
+	policy.schedule(shutdownTestNow.Add(24*time.Hour), endTime)
+	if left := policy.SnoozesLeft(); left != 1 {
+		t.Errorf("SnoozesLeft() = %d for the next shutdown, want 1", left)
+	}
+}
+
+func TestShutdownPolicyImpl_SnoozeUnavailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		snooze  SnoozeConfig
+		pending bool
+	}{
+		{name: "not configured", pending: true},
+		{name: "no pending shutdown", snooze: SnoozeConfig{Count: 1, Minutes: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, _ := newPostponableShutdownPolicy(fake.NewClock(shutdownTestNow), nil, nil)
+			policy.SetSnooze(tt.snooze)
+			if tt.pending {
+				policy.schedule(shutdownTestNow, shutdownTestNow.Add(time.Hour))
+			}
+			if _, err := policy.Snooze(); err == nil {
+				t.Error("Expected Snooze() to fail")
+			}
+			if left := policy.SnoozesLeft(); left != 0 {
+				t.Errorf("SnoozesLeft() = %d, want 0", left)
+			}
+		})
+	}
 }
@@ -0,0 +1,126 @@
+package sleego
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Version of the state file format written by this build
+const stateSchemaVersion = 1
+
+// Name of the state file inside the state directory
+const stateFileName = "state.json"
+
+// StateStore defines the behavior for persisting state across restarts
+type StateStore interface {
+	Load() (State, error)
+	Save(state State) error
+}
+
+// State is everything the policies need to survive a restart or a reboot
+type State struct {
+	Version   int                `json:"version"`
+	SavedAt   time.Time          `json:"saved_at"`
+	Usage     UsageState         `json:"usage"`
+	Suspended []SuspendedProcess `json:"suspended,omitempty"`
+}
+
+// SuspendedProcess is a process left frozen by the policy. The start time
+// tells it apart from a later process reusing its PID.
+type SuspendedProcess struct {
+	Pid       int       `json:"pid"`
+	Name      string    `json:"name"`
+	StartTime time.Time `json:"start_time,omitzero"`
+}
+
+// UsageState is the quota usage of the current period, in nanoseconds per rule
+type UsageState struct {
+	Period time.Time                `json:"period"`
+	Used   map[string]time.Duration `json:"used"`
+}
+
+// FileStateStore keeps the state as a JSON file inside a directory
+type FileStateStore struct {
+	dir string
+}
+
+// NewFileStateStore creates a store that keeps its file inside dir
+func NewFileStateStore(dir string) *FileStateStore {
+	return &FileStateStore{dir: dir}
+}
+
+// Path returns the location of the state file
+func (f *FileStateStore) Path() string {
+	return filepath.Join(f.dir, stateFileName)
+}
+
+// Load reads the state file. A missing file is not an error and yields an empty state.
+func (f *FileStateStore) Load() (State, error) {
+	data, err := os.ReadFile(f.Path())
+	if errors.Is(err, os.ErrNotExist) {
+		return State{Version: stateSchemaVersion}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, fmt.Errorf("error decoding state file %s: %w", f.Path(), err)
+	}
+	switch {
+	case state.Version == 0:
+		return State{}, fmt.Errorf("state file %s has no schema version", f.Path())
+	case state.Version > stateSchemaVersion:
+		return State{}, fmt.Errorf("state file %s uses schema version %d, newer than the supported %d", f.Path(), state.Version, stateSchemaVersion)
+	}
+	return state, nil
+}
+
+// Save writes the state atomically: the data goes to a temporary file in the
+// same directory, is flushed to disk and then renamed over the previous file,
+// so a crash leaves either the old or the new state but never a partial one.
+func (f *FileStateStore) Save(state State) error {
+	state.Version = stateSchemaVersion
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, stateFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.Path()); err != nil {
+		return err
+	}
+
+	// Persist the rename itself; not every platform supports syncing a directory
+	if dir, err := os.Open(f.dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+var _ StateStore = &FileStateStore{}
@@ -0,0 +1,79 @@
+package sleego
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStateStore_LoadMissingFile(t *testing.T) {
+	store := NewFileStateStore(t.TempDir())
+
+	state, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if state.Version != stateSchemaVersion {
+		t.Errorf("Expected version %d, got %d", stateSchemaVersion, state.Version)
+	}
+	if len(state.Usage.Used) != 0 {
+		t.Errorf("Expected empty usage, got %v", state.Usage.Used)
+	}
+}
+
+func TestFileStateStore_SaveAndLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "sleego")
+	store := NewFileStateStore(dir)
+	period := time.Date(2023, 10, 10, 0, 0, 0, 0, time.UTC)
+
+	err := store.Save(State{Usage: UsageState{Period: period, Used: map[string]time.Duration{"games": 90 * time.Minute}}})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	state, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if state.Version != stateSchemaVersion {
+		t.Errorf("Expected version %d, got %d", stateSchemaVersion, state.Version)
+	}
+	if !state.Usage.Period.Equal(period) {
+		t.Errorf("Expected period %v, got %v", period, state.Usage.Period)
+	}
+	if got := state.Usage.Used["games"]; got != 90*time.Minute {
+		t.Errorf("Expected games usage 1h30m, got %v", got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != stateFileName {
+		t.Errorf("Expected only %s in the state dir, got %v", stateFileName, entries)
+	}
+}
+
+func TestFileStateStore_RejectsUnknownVersion(t *testing.T) {
+	dir := t.TempDir()
+	content := `{"version": 99, "usage": {}}`
+	if err := os.WriteFile(filepath.Join(dir, stateFileName), []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+
+	if _, err := NewFileStateStore(dir).Load(); err == nil {
+		t.Error("Expected error for a newer schema version, got nil")
+	}
+}
+
+func TestFileStateStore_RejectsCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, stateFileName), []byte(`{"version": 1,`), 0600); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+
+	if _, err := NewFileStateStore(dir).Load(); err == nil {
+		t.Error("Expected error for a corrupt state file, got nil")
+	}
+}
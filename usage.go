@@ -0,0 +1,90 @@
+package sleego
+
+import (
+	"sync"
+	"time"
+)
+
+// usageTracker accumulates how long each rule has been observed running in
+// the current quota period. A period starts every day at resetAt.
+type usageTracker struct {
+	mu      sync.Mutex
+	resetAt time.Duration // offset from midnight at which a new period starts
+	period  time.Time     // start of the period the usage belongs to
+	used    map[string]time.Duration
+}
+
+func newUsageTracker(resetAt time.Duration) *usageTracker {
+	return &usageTracker{resetAt: resetAt, used: make(map[string]time.Duration)}
+}
+
+// periodStart returns the start of the quota period that contains now
+func (u *usageTracker) periodStart(now time.Time) time.Time {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.periodStartLocked(now)
+}
+
+func (u *usageTracker) periodStartLocked(now time.Time) time.Time {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(u.resetAt)
+	if now.Before(start) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// rollover clears the usage when now belongs to a newer period. Callers must hold mu.
+func (u *usageTracker) rollover(now time.Time) {
+	start := u.periodStartLocked(now)
+	if !u.period.Equal(start) {
+		u.period = start
+		u.used = make(map[string]time.Duration)
+	}
+}
+
+// setResetAt moves the start of the quota periods. The usage of the current
+// period is kept, so changing the reset time does not hand out a new quota.
+func (u *usageTracker) setResetAt(resetAt time.Duration, now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover(now)
+	u.resetAt = resetAt
+	u.period = u.periodStartLocked(now)
+}
+
+func (u *usageTracker) add(name string, d time.Duration, now time.Time) time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover(now)
+	u.used[name] += d
+	return u.used[name]
+}
+
+func (u *usageTracker) get(name string, now time.Time) time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover(now)
+	return u.used[name]
+}
+
+func (u *usageTracker) snapshot() UsageState {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	used := make(map[string]time.Duration, len(u.used))
+	for name, d := range u.used {
+		used[name] = d
+	}
+	return UsageState{Period: u.period, Used: used}
+}
+
+// restore replaces the usage with a saved one. Usage from an older period is
+// discarded on the next access.
+func (u *usageTracker) restore(state UsageState) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.period = state.Period
+	u.used = make(map[string]time.Duration, len(state.Used))
+	for name, d := range state.Used {
+		u.used[name] = d
+	}
+}
@@ -0,0 +1,36 @@
+package sleego
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsageTracker_AccumulatesWithinPeriod(t *testing.T) {
+	tracker := newUsageTracker(0)
+	now := time.Date(2023, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	tracker.add("games", 5*time.Second, now)
+	tracker.add("games", 5*time.Second, now.Add(5*time.Second))
+	tracker.add("browsers", time.Second, now)
+
+	if got := tracker.get("games", now.Add(10*time.Second)); got != 10*time.Second {
+		t.Errorf("games usage = %v, want 10s", got)
+	}
+	if got := tracker.get("browsers", now); got != time.Second {
+		t.Errorf("browsers usage = %v, want 1s", got)
+	}
+}
+
+func TestUsageTracker_ResetsAtConfiguredTime(t *testing.T) {
+	tracker := newUsageTracker(4 * time.Hour)
+	lateNight := time.Date(2023, 10, 11, 2, 0, 0, 0, time.UTC)
+
+	tracker.add("games", time.Hour, time.Date(2023, 10, 10, 22, 0, 0, 0, time.UTC))
+
+	if got := tracker.get("games", lateNight); got != time.Hour {
+		t.Errorf("usage before the reset time = %v, want 1h", got)
+	}
+	if got := tracker.get("games", time.Date(2023, 10, 11, 4, 0, 0, 0, time.UTC)); got != 0 {
+		t.Errorf("usage after the reset time = %v, want 0", got)
+	}
+}
@@ -0,0 +1,166 @@
+package sleego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+const (
+	// Default time each webhook request may take
+	defaultWebhookTimeout = 10 * time.Second
+	// Longest timeout of a webhook request
+	maxWebhookTimeout = time.Minute
+	// Most times a failed webhook request is retried
+	maxWebhookRetries = 10
+	// Wait before the first retry of a webhook request, doubled for each retry after it
+	webhookBackoff = time.Second
+)
+
+// WebhookNotifier posts each event to an HTTP endpoint. The body is the event
+// as JSON unless a template is configured. Requests that fail with a network
+// error, a 429 or a 5xx status are retried with exponential backoff.
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+	body    *template.Template
+	retries int
+	timeout time.Duration
+	backoff time.Duration
+	client  *http.Client
+}
+
+// newWebhookNotifier creates the webhook notifier configured in cfg
+func newWebhookNotifier(cfg NotifierConfig) (*WebhookNotifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("the %s notifier needs a url", NotifierWebhook)
+	}
+	target, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("invalid url %q, must be an http or https URL", cfg.URL)
+	}
+	if cfg.Retries < 0 || cfg.Retries > maxWebhookRetries {
+		return nil, fmt.Errorf("invalid retries %d, must be between 0 and %d", cfg.Retries, maxWebhookRetries)
+	}
+
+	timeout := defaultWebhookTimeout
+	if cfg.Timeout != "" {
+		timeout, err = time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		if timeout <= 0 || timeout > maxWebhookTimeout {
+			return nil, fmt.Errorf("invalid timeout %s, must be positive and at most %s", timeout, maxWebhookTimeout)
+		}
+	}
+
+	w := &WebhookNotifier{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		retries: cfg.Retries,
+		timeout: timeout,
+		backoff: webhookBackoff,
+		client:  http.DefaultClient,
+	}
+	if cfg.Body != "" {
+		w.body, err = template.New("body").Funcs(template.FuncMap{"json": jsonValue}).Parse(cfg.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body template: %w", err)
+		}
+	}
+	return w, nil
+}
+
+// jsonValue encodes a value as JSON, so templates can quote strings safely
+func jsonValue(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := w.render(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		err = w.post(ctx, body)
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) || attempt == w.retries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w, giving up: %w", err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// render builds the request body for the event
+func (w *WebhookNotifier) render(event Event) ([]byte, error) {
+	if w.body == nil {
+		return json.Marshal(event)
+	}
+	var buf bytes.Buffer
+	if err := w.body.Execute(&buf, event); err != nil {
+		return nil, fmt.Errorf("error rendering webhook body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// permanentError is a webhook failure that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// post sends the body once
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sleego")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook %s: %s", w.url, resp.Status)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return &permanentError{err}
+}
+
+var _ Notifier = &WebhookNotifier{}
@@ -0,0 +1,207 @@
+package sleego
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookServer answers with the given statuses in turn, the last one for
+// every request after them, and records the requests it received
+type webhookServer struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) (*webhookServer, *httptest.Server) {
+	s := &webhookServer{statuses: statuses}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	status := s.statuses[min(len(s.requests), len(s.statuses))-1]
+	s.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (s *webhookServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func newTestWebhook(t *testing.T, cfg NotifierConfig) *WebhookNotifier {
+	cfg.Type = NotifierWebhook
+	webhook, err := newWebhookNotifier(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create webhook notifier: %v", err)
+	}
+	webhook.backoff = time.Millisecond
+	return webhook
+}
+
+func TestNewWebhookNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     NotifierConfig
+		wantErr bool
+	}{
+		{name: "url only", cfg: NotifierConfig{URL: "https://example.com/hook"}},
+		{name: "every option", cfg: NotifierConfig{URL: "http://localhost:8080/hook", Headers: map[string]string{"Authorization": "Bearer token"}, Body: `{"text": {{json .Message}}}`, Retries: 3, Timeout: "5s"}},
+		{name: "missing url", cfg: NotifierConfig{}, wantErr: true},
+		{name: "url without scheme", cfg: NotifierConfig{URL: "example.com/hook"}, wantErr: true},
+		{name: "unsupported scheme", cfg: NotifierConfig{URL: "ftp://example.com/hook"}, wantErr: true},
+		{name: "negative retries", cfg: NotifierConfig{URL: "https://example.com", Retries: -1}, wantErr: true},
+		{name: "too many retries", cfg: NotifierConfig{URL: "https://example.com", Retries: maxWebhookRetries + 1}, wantErr: true},
+		{name: "invalid timeout", cfg: NotifierConfig{URL: "https://example.com", Timeout: "soon"}, wantErr: true},
+		{name: "timeout too long", cfg: NotifierConfig{URL: "https://example.com", Timeout: "2m"}, wantErr: true},
+		{name: "invalid body template", cfg: NotifierConfig{URL: "https://example.com", Body: "{{.Message"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Type = NotifierWebhook
+			_, err := NewNotifier(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier_PostsEventAsJSON(t *testing.T) {
+	recorder, server := newWebhookServer(t, http.StatusNoContent)
+	webhook := newTestWebhook(t, NotifierConfig{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+
+	if err := webhook.Notify(context.Background(), testEvent); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if recorder.count() != 1 {
+		t.Fatalf("Expected 1 request, got %d", recorder.count())
+	}
+	req := recorder.requests[0]
+	if req.Method != http.MethodPost {
+		t.Errorf("Expected a POST, got %s", req.Method)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Expected the configured header, got %q", got)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected a JSON content type, got %q", got)
+	}
+	var got Event
+	if err := json.Unmarshal([]byte(recorder.bodies[0]), &got); err != nil {
+		t.Fatalf("Expected the event as JSON, got %q: %v", recorder.bodies[0], err)
+	}
+	if got.Kind != testEvent.Kind || got.Pid != testEvent.Pid || got.Message != testEvent.Message {
+		t.Errorf("Expected %+v, got %+v", testEvent, got)
+	}
+}
+
+func TestWebhookNotifier_RendersBodyTemplate(t *testing.T) {
+	recorder, server := newWebhookServer(t, http.StatusOK)
+	webhook := newTestWebhook(t, NotifierConfig{URL: server.URL, Body: `{"text": {{json .Message}}, "kind": "{{.Kind}}"}`})
+
+	event := testEvent
+	event.Message = `Killing "steam"`
+	if err := webhook.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := `{"text": "Killing \"steam\"", "kind": "process_killed"}`
+	if recorder.bodies[0] != expected {
+		t.Errorf("Expected body %s, got %s", expected, recorder.bodies[0])
+	}
+}
+
+func TestWebhookNotifier_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		retries      int
+		wantErr      bool
+		wantRequests int
+	}{
+		{name: "succeeds after server errors", statuses: []int{500, 503, 200}, retries: 3, wantRequests: 3},
+		{name: "retries when rate limited", statuses: []int{429, 200}, retries: 1, wantRequests: 2},
+		{name: "gives up after retries", statuses: []int{502}, retries: 2, wantErr: true, wantRequests: 3},
+		{name: "no retries configured", statuses: []int{500}, retries: 0, wantErr: true, wantRequests: 1},
+		{name: "client error is not retried", statuses: []int{400}, retries: 3, wantErr: true, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, server := newWebhookServer(t, tt.statuses...)
+			webhook := newTestWebhook(t, NotifierConfig{URL: server.URL, Retries: tt.retries})
+
+			err := webhook.Notify(context.Background(), testEvent)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if recorder.count() != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, recorder.count())
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	defer close(release)
+	webhook := newTestWebhook(t, NotifierConfig{URL: server.URL, Timeout: "50ms"})
+
+	start := time.Now()
+	if err := webhook.Notify(context.Background(), testEvent); err == nil {
+		t.Fatal("Expected the request to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the timeout to cut the request short, took %v", elapsed)
+	}
+}
+
+func TestWebhookNotifier_StopsRetryingWhenCancelled(t *testing.T) {
+	recorder, server := newWebhookServer(t, http.StatusInternalServerError)
+	webhook := newTestWebhook(t, NotifierConfig{URL: server.URL, Retries: 5})
+	webhook.backoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- webhook.Notify(ctx, testEvent) }()
+	for recorder.count() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected an error once cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Notify to return once cancelled")
+	}
+	if recorder.count() != 1 {
+		t.Errorf("Expected 1 request, got %d", recorder.count())
+	}
+}